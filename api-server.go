@@ -16,22 +16,32 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
+
+	"release-crawler/internal/search"
 )
 
-type Article struct {
-	ID        string `json:"id"`
-	Title     string `json:"title"`
-	Body      string `json:"body"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
-	HTMLURL   string `json:"url"`
-	SectionID int64  `json:"section_id"`
-}
+// The search programs share the article model and query building in internal/search
+type (
+	Article          = search.Article
+	Passage          = search.Passage
+	DuplicateArticle = search.DuplicateArticle
+)
 
 type SearchRequest struct {
-	Query string `json:"query" form:"q" binding:"required"`
-	From  int    `json:"from" form:"from"`
-	Size  int    `json:"size" form:"size"`
+	Query    string `json:"query" form:"q" binding:"required"`
+	From     int    `json:"from" form:"from"`
+	Size     int    `json:"size" form:"size"`
+	Section  string `json:"section" form:"section"`   // section ID or name
+	Category string `json:"category" form:"category"` // category ID or name (product area)
+	Collapse *bool  `json:"collapse" form:"collapse"` // fold near-duplicates into one result, on unless false
+	Lang     string `json:"lang" form:"lang"`         // language (pt) or locale (pt-br), English by default
+	Source   string `json:"source" form:"source"`     // name of a crawled site
+}
+
+// FacetBucket is one value of a facet with the number of matching articles
+type FacetBucket struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
 }
 
 type SearchAPIResponse struct {
@@ -46,6 +56,8 @@ type SearchAPIResponse struct {
 	NextPage       int       `json:"next_page"`
 	ResultsPerPage int       `json:"results_per_page"`
 	SearchTime     string    `json:"search_time"`
+	// Facets counts matches per category, section and source so clients can group by product area or site
+	Facets map[string][]FacetBucket `json:"facets,omitempty"`
 }
 
 type ElasticsearchResponse struct {
@@ -54,10 +66,75 @@ type ElasticsearchResponse struct {
 			Value int `json:"value"`
 		} `json:"total"`
 		Hits []struct {
-			Source Article `json:"_source"`
-			Score  float64 `json:"_score"`
+			Source    Article `json:"_source"`
+			Score     float64 `json:"_score"`
+			InnerHits map[string]struct {
+				Hits struct {
+					Hits []struct {
+						Source json.RawMessage `json:"_source"` // a Passage or a DuplicateArticle
+					} `json:"hits"`
+				} `json:"hits"`
+			} `json:"inner_hits"`
 		} `json:"hits"`
 	} `json:"hits"`
+	Aggregations map[string]struct {
+		Buckets []struct {
+			Key      string `json:"key"`
+			DocCount int    `json:"doc_count"`
+		} `json:"buckets"`
+		Value int `json:"value"` // cardinality aggregations
+	} `json:"aggregations"`
+}
+
+// ReleaseEntry is one change parsed from a release-notes article by the crawler
+type ReleaseEntry struct {
+	ID           string `json:"id"`
+	ArticleID    string `json:"article_id"`
+	ArticleTitle string `json:"article_title"`
+	ArticleURL   string `json:"article_url"`
+	URL          string `json:"url"`
+	Feature      string `json:"feature"`
+	ProductArea  string `json:"product_area,omitempty"`
+	Type         string `json:"type,omitempty"`
+	ReleaseDate  string `json:"release_date,omitempty"`
+	Availability string `json:"availability,omitempty"`
+	Description  string `json:"description"`
+}
+
+type ReleasesRequest struct {
+	Query       string `form:"q"`
+	Type        string `form:"type"`         // New, Improved, Fixed or Deprecated
+	ProductArea string `form:"product_area"` // exact product area, e.g. "Talkdesk Studio"
+	Since       string `form:"since"`        // YYYY-MM-DD, inclusive
+	Until       string `form:"until"`        // YYYY-MM-DD, inclusive
+	From        int    `form:"from"`
+	Size        int    `form:"size"`
+}
+
+type ReleasesResponse struct {
+	Entries []ReleaseEntry `json:"entries"`
+	Total   int            `json:"total"`
+}
+
+// Revision is a snapshot of an article recorded by the crawler whenever its text changed
+type Revision struct {
+	ArticleID     string `json:"article_id"`
+	Revision      int    `json:"revision"`
+	Title         string `json:"title"`
+	URL           string `json:"url"`
+	Text          string `json:"text,omitempty"`
+	PreviousTitle string `json:"previous_title,omitempty"`
+	Diff          string `json:"diff,omitempty"` // unified diff against the previous revision
+	LinesAdded    int    `json:"lines_added"`
+	LinesRemoved  int    `json:"lines_removed"`
+	UpdatedAt     string `json:"updated_at,omitempty"`
+	CapturedAt    string `json:"captured_at"`
+}
+
+type HistoryResponse struct {
+	ArticleID string     `json:"article_id"`
+	Revisions []Revision `json:"revisions"` // newest first, without text and diff
+	Total     int        `json:"total"`
 }
 
 type AutocompleteResponse struct {
@@ -112,6 +189,13 @@ func main() {
 	r.GET("/search", searchHandler)
 	r.POST("/search", searchHandler)
 
+	// Release entries endpoint
+	r.GET("/releases", releasesHandler)
+
+	// Article change history endpoints
+	r.GET("/articles/:id/history", historyHandler)
+	r.GET("/articles/:id/diff", diffHandler)
+
 	// Autocomplete endpoint
 	r.GET("/autocomplete", autocompleteHandler)
 
@@ -128,6 +212,9 @@ func main() {
 	fmt.Printf("   • Network: http://YOUR_IP:%s\n", port)
 	fmt.Println("🔍 API Endpoints:")
 	fmt.Printf("   • GET/POST /search - Search documentation\n")
+	fmt.Printf("   • GET /releases - Search individual release-note entries\n")
+	fmt.Printf("   • GET /articles/:id/history - List an article's revisions\n")
+	fmt.Printf("   • GET /articles/:id/diff - Show what changed in a revision\n")
 	fmt.Printf("   • GET /autocomplete - Get search suggestions\n")
 	fmt.Printf("   • POST /slack/events - Slack events webhook\n")
 	fmt.Printf("   • POST /slack/commands - Slack commands webhook\n")
@@ -164,7 +251,12 @@ func searchHandler(c *gin.Context) {
 		page = 1
 	}
 
-	articles, total, err := searchElasticsearch(req.Query, req.From, req.Size)
+	filters := search.Filters{Section: strings.TrimSpace(req.Section), Category: strings.TrimSpace(req.Category), Source: strings.TrimSpace(req.Source), Language: strings.TrimSpace(req.Lang)}
+	if filters.Language == "" {
+		filters.Language = "en"
+	}
+	collapse := req.Collapse == nil || *req.Collapse
+	articles, total, facets, err := searchElasticsearch(req.Query, req.From, req.Size, filters, collapse)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "Search failed", "details": err.Error()})
 		return
@@ -187,11 +279,80 @@ func searchHandler(c *gin.Context) {
 		NextPage:       page + 1,
 		ResultsPerPage: req.Size,
 		SearchTime:     fmt.Sprintf("%.2fms", float64(time.Since(startTime).Nanoseconds())/1000000),
+		Facets:         facets,
 	}
 
 	c.JSON(200, response)
 }
 
+func releasesHandler(c *gin.Context) {
+	var req ReleasesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request parameters", "details": err.Error()})
+		return
+	}
+	if req.Size <= 0 || req.Size > 100 {
+		req.Size = 20
+	}
+	if req.From < 0 {
+		req.From = 0
+	}
+
+	entries, total, err := searchReleaseEntries(req)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Release search failed", "details": err.Error()})
+		return
+	}
+
+	c.JSON(200, ReleasesResponse{Entries: entries, Total: total})
+}
+
+func historyHandler(c *gin.Context) {
+	articleID := c.Param("id")
+	from, _ := strconv.Atoi(c.DefaultQuery("from", "0"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "50"))
+	if size <= 0 || size > 100 {
+		size = 50
+	}
+	if from < 0 {
+		from = 0
+	}
+
+	revisions, total, err := searchRevisions(articleID, 0, from, size, false)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "History lookup failed", "details": err.Error()})
+		return
+	}
+	if total == 0 {
+		c.JSON(404, gin.H{"error": "No revisions recorded for this article"})
+		return
+	}
+
+	c.JSON(200, HistoryResponse{ArticleID: articleID, Revisions: revisions, Total: total})
+}
+
+// diffHandler returns one revision with its text and the diff against the revision before it,
+// the latest one unless ?revision= is given
+func diffHandler(c *gin.Context) {
+	revision, err := strconv.Atoi(c.DefaultQuery("revision", "0"))
+	if err != nil || revision < 0 {
+		c.JSON(400, gin.H{"error": "Invalid revision"})
+		return
+	}
+
+	revisions, _, err := searchRevisions(c.Param("id"), revision, 0, 1, true)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Diff lookup failed", "details": err.Error()})
+		return
+	}
+	if len(revisions) == 0 {
+		c.JSON(404, gin.H{"error": "Revision not found"})
+		return
+	}
+
+	c.JSON(200, revisions[0])
+}
+
 func autocompleteHandler(c *gin.Context) {
 	query := strings.TrimSpace(c.Query("q"))
 	if len(query) < 2 {
@@ -293,7 +454,7 @@ func handleSearchCommand(query, userID, channelID, responseURL string) {
 }
 
 func performSlackSearch(query, channelID, userID string) {
-	articles, total, err := searchElasticsearch(query, 0, 5) // Limit to 5 results for Slack
+	articles, total, _, err := searchElasticsearch(query, 0, 5, search.Filters{Language: "en"}, true) // Limit to 5 results for Slack
 	if err != nil {
 		sendSlackMessage(channelID, fmt.Sprintf("❌ Search failed: %v", err))
 		return
@@ -319,10 +480,7 @@ func performSlackSearch(query, channelID, userID string) {
 		}
 
 		// Truncate body for Slack display
-		body := cleanTextForSlack(article.Body)
-		if len(body) > 300 {
-			body = body[:300] + "..."
-		}
+		body := slackPreview(article.BodyText, 300)
 
 		attachment := slack.Attachment{
 			Color:     color,
@@ -332,17 +490,30 @@ func performSlackSearch(query, channelID, userID string) {
 			Fields: []slack.AttachmentField{
 				{
 					Title: "Created",
-					Value: article.CreatedAt,
+					Value: orUnknown(article.CreatedAt),
 					Short: true,
 				},
 				{
 					Title: "Updated",
-					Value: article.UpdatedAt,
+					Value: orUnknown(article.UpdatedAt),
 					Short: true,
 				},
 			},
 			Footer: fmt.Sprintf("Article ID: %s", article.ID),
 		}
+		if len(article.Breadcrumbs) > 0 {
+			attachment.Fields = append(attachment.Fields, slack.AttachmentField{
+				Title: "Section",
+				Value: strings.Join(article.Breadcrumbs, " › "),
+			})
+		}
+		// Link straight to the section that matched when it isn't the article's introduction
+		if article.BestPassage != nil && article.BestPassage.URL != article.HTMLURL {
+			attachment.Fields = append(attachment.Fields, slack.AttachmentField{
+				Title: "Best match",
+				Value: fmt.Sprintf("<%s|%s>", article.BestPassage.URL, article.BestPassage.Title),
+			})
+		}
 		attachments = append(attachments, attachment)
 	}
 
@@ -357,6 +528,14 @@ func performSlackSearch(query, channelID, userID string) {
 	}
 }
 
+// orUnknown labels dates the crawler couldn't find on the article
+func orUnknown(date string) string {
+	if date == "" {
+		return "Unknown"
+	}
+	return date
+}
+
 func sendSlackMessage(channelID, text string) {
 	api := slack.New(getEnv("SLACK_BOT_TOKEN", ""))
 	_, _, err := api.PostMessage(channelID, slack.MsgOptionText(text, false))
@@ -379,66 +558,31 @@ func getBotUserID() string {
 	return getEnv("SLACK_BOT_USER_ID", "")
 }
 
-func cleanTextForSlack(text string) string {
-	// Remove HTML tags and clean up text for Slack display
-	text = strings.ReplaceAll(text, "&lt;", "<")
-	text = strings.ReplaceAll(text, "&gt;", ">")
-	text = strings.ReplaceAll(text, "&amp;", "&")
-	text = strings.ReplaceAll(text, "&quot;", "\"")
-	text = strings.ReplaceAll(text, "&nbsp;", " ")
-	
-	// Remove HTML tags
-	text = removeHTMLTags(text)
-	
-	// Clean up whitespace
-	lines := strings.Split(text, "\n")
-	var cleanLines []string
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" {
-			cleanLines = append(cleanLines, line)
-		}
-	}
-	text = strings.Join(cleanLines, "\n")
-	
-	// Remove excessive newlines
-	for strings.Contains(text, "\n\n\n") {
-		text = strings.ReplaceAll(text, "\n\n\n", "\n\n")
-	}
-	
-	return strings.TrimSpace(text)
-}
-
-func removeHTMLTags(text string) string {
-	result := ""
-	inTag := false
-	
-	for _, char := range text {
-		if char == '<' {
-			inTag = true
-		} else if char == '>' {
-			inTag = false
-		} else if !inTag {
-			result += string(char)
-		}
+// slackPreview cuts an article's plain-text body, rendered by the crawler, to fit an attachment
+func slackPreview(text string, limit int) string {
+	if runes := []rune(text); len(runes) > limit {
+		return strings.TrimSpace(string(runes[:limit])) + "..."
 	}
-	
-	return result
+	return text
 }
 
-func searchElasticsearch(query string, from, size int) ([]Article, int, error) {
+func searchElasticsearch(query string, from, size int, filters search.Filters, collapse bool) ([]Article, int, map[string][]FacetBucket, error) {
 	// Check cache first
-	cacheKey := fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("%s-%d-%d", query, from, size))))
+	cacheKey := fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("%s-%d-%d-%s-%s-%s-%s-%t", query, from, size, filters.Section, filters.Category, filters.Source, filters.Language, collapse))))
 	if cachedResult, found := getCachedResult(cacheKey); found {
-		return cachedResult.Articles, cachedResult.Total, nil
+		return cachedResult.Articles, cachedResult.Total, cachedResult.Facets, nil
 	}
 
 	// Build enhanced query with fuzzy search and phrase detection
-	esQuery := buildEnhancedQuery(query, from, size)
+	esQuery := search.Query(query, from, size, filters)
+	esQuery["aggs"] = hierarchyAggregations()
+	if collapse {
+		search.CollapseDuplicates(esQuery)
+	}
 
 	jsonData, err := json.Marshal(esQuery)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 
 	client := &http.Client{Timeout: 10 * time.Second}
@@ -446,128 +590,68 @@ func searchElasticsearch(query string, from, size int) ([]Article, int, error) {
 	indexName := getEnv("ELASTICSEARCH_INDEX", "documentation-articles")
 	resp, err := client.Post(fmt.Sprintf("%s/%s/_search", esURL, indexName), "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 	defer resp.Body.Close()
 
 	var searchResp ElasticsearchResponse
 	if err := json.NewDecoder(resp.Body).Decode(&searchResp); err != nil {
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 
 	var articles []Article
 	for _, hit := range searchResp.Hits.Hits {
-		articles = append(articles, hit.Source)
+		article := hit.Source
+		if passages := hit.InnerHits["passages"].Hits.Hits; len(passages) > 0 {
+			var passage Passage
+			if json.Unmarshal(passages[0].Source, &passage) == nil {
+				article.BestPassage = &passage
+			}
+		}
+		for _, duplicate := range hit.InnerHits["duplicates"].Hits.Hits {
+			var other DuplicateArticle
+			if json.Unmarshal(duplicate.Source, &other) == nil && other.ID != article.ID {
+				article.Duplicates = append(article.Duplicates, other)
+			}
+		}
+		articles = append(articles, article)
+	}
+
+	// Collapsed results are paged by cluster of near-duplicates, not by article
+	total := searchResp.Hits.Total.Value
+	if collapse {
+		total = searchResp.Aggregations["clusters"].Value
+	}
+
+	facets := make(map[string][]FacetBucket)
+	for name, agg := range searchResp.Aggregations {
+		for _, bucket := range agg.Buckets {
+			facets[name] = append(facets[name], FacetBucket{Value: bucket.Key, Count: bucket.DocCount})
+		}
 	}
 
 	// Cache the result
 	result := SearchAPIResponse{
 		Articles: articles,
-		Total:    searchResp.Hits.Total.Value,
+		Total:    total,
+		Facets:   facets,
 	}
 	cacheResult(cacheKey, result)
 
-	return articles, searchResp.Hits.Total.Value, nil
+	return articles, total, facets, nil
 }
 
-func buildEnhancedQuery(query string, from, size int) map[string]interface{} {
-	// Detect if it's a phrase search (quoted)
-	isPhrase := strings.HasPrefix(query, "\"") && strings.HasSuffix(query, "\"")
-	if isPhrase {
-		query = strings.Trim(query, "\"")
-		return buildPhraseQuery(query, from, size)
-	}
-
-	// Use function scoring with recency boost
+// hierarchyAggregations groups matches by category (product area), section and crawled site
+func hierarchyAggregations() map[string]interface{} {
 	return map[string]interface{}{
-		"query": map[string]interface{}{
-			"function_score": map[string]interface{}{
-				"query": map[string]interface{}{
-					"bool": map[string]interface{}{
-						"should": []map[string]interface{}{
-							// Exact match (highest boost)
-							{
-								"multi_match": map[string]interface{}{
-									"query":  query,
-									"fields": []string{"title^5", "body^2"},
-									"type":   "phrase",
-									"boost":  10,
-								},
-							},
-							// Fuzzy match for typos
-							{
-								"multi_match": map[string]interface{}{
-									"query":     query,
-									"fields":    []string{"title^3", "body^1"},
-									"fuzziness": "AUTO",
-									"boost":     5,
-								},
-							},
-							// Prefix match for partial typing
-							{
-								"multi_match": map[string]interface{}{
-									"query":  query,
-									"fields": []string{"title^2", "body^1"},
-									"type":   "phrase_prefix",
-									"boost":  3,
-								},
-							},
-						},
-						"minimum_should_match": 1,
-					},
-				},
-				"boost_mode": "multiply",
-				"functions": []map[string]interface{}{
-					{
-						"gauss": map[string]interface{}{
-							"updated_at": map[string]interface{}{
-								"scale": "30d",
-								"decay": 0.5,
-							},
-						},
-						"weight": 1.2,
-					},
-				},
-			},
+		"sources": map[string]interface{}{
+			"terms": map[string]interface{}{"field": "source", "size": 20},
 		},
-		"from": from,
-		"size": size,
-		"sort": []map[string]interface{}{
-			{"_score": map[string]string{"order": "desc"}},
+		"categories": map[string]interface{}{
+			"terms": map[string]interface{}{"field": "category_name.keyword", "size": 20},
 		},
-		"highlight": map[string]interface{}{
-			"fields": map[string]interface{}{
-				"title": map[string]interface{}{"fragment_size": 150},
-				"body":  map[string]interface{}{"fragment_size": 300},
-			},
-			"pre_tags":  []string{"<mark>"},
-			"post_tags": []string{"</mark>"},
-		},
-	}
-}
-
-func buildPhraseQuery(query string, from, size int) map[string]interface{} {
-	return map[string]interface{}{
-		"query": map[string]interface{}{
-			"multi_match": map[string]interface{}{
-				"query":  query,
-				"fields": []string{"title^3", "body^1"},
-				"type":   "phrase",
-			},
-		},
-		"from": from,
-		"size": size,
-		"sort": []map[string]interface{}{
-			{"_score": map[string]string{"order": "desc"}},
-			{"updated_at": map[string]string{"order": "desc"}},
-		},
-		"highlight": map[string]interface{}{
-			"fields": map[string]interface{}{
-				"title": map[string]interface{}{},
-				"body":  map[string]interface{}{},
-			},
-			"pre_tags":  []string{"<mark>"},
-			"post_tags": []string{"</mark>"},
+		"sections": map[string]interface{}{
+			"terms": map[string]interface{}{"field": "section_name.keyword", "size": 50},
 		},
 	}
 }
@@ -609,6 +693,158 @@ func cacheResult(key string, result SearchAPIResponse) {
 	}
 }
 
+// searchReleaseEntries answers "when did X ship": matches on the feature name first and
+// returns the newest entries first when there is no text query
+func searchReleaseEntries(req ReleasesRequest) ([]ReleaseEntry, int, error) {
+	var must []map[string]interface{}
+	var filter []map[string]interface{}
+
+	if q := strings.TrimSpace(req.Query); q != "" {
+		must = append(must, map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":     q,
+				"fields":    []string{"feature^3", "product_area^2", "description", "article_title"},
+				"fuzziness": "AUTO",
+			},
+		})
+	}
+	if req.Type != "" {
+		filter = append(filter, map[string]interface{}{"term": map[string]interface{}{"type": req.Type}})
+	}
+	if req.ProductArea != "" {
+		filter = append(filter, map[string]interface{}{"term": map[string]interface{}{"product_area.keyword": req.ProductArea}})
+	}
+	if req.Since != "" || req.Until != "" {
+		dateRange := map[string]interface{}{}
+		if req.Since != "" {
+			dateRange["gte"] = req.Since
+		}
+		if req.Until != "" {
+			dateRange["lte"] = req.Until
+		}
+		filter = append(filter, map[string]interface{}{"range": map[string]interface{}{"release_date": dateRange}})
+	}
+
+	esQuery := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must":   must,
+				"filter": filter,
+			},
+		},
+		"from": req.From,
+		"size": req.Size,
+	}
+	if len(must) == 0 {
+		esQuery["sort"] = []map[string]interface{}{
+			{"release_date": map[string]interface{}{"order": "desc", "missing": "_last"}},
+		}
+	}
+
+	jsonData, err := json.Marshal(esQuery)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	esURL := getEnv("ELASTICSEARCH_URL", "http://localhost:9200")
+	indexName := getEnv("ELASTICSEARCH_RELEASES_INDEX", "release-entries")
+	resp, err := client.Post(fmt.Sprintf("%s/%s/_search", esURL, indexName), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("elasticsearch returned status %d", resp.StatusCode)
+	}
+
+	var searchResp struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source ReleaseEntry `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&searchResp); err != nil {
+		return nil, 0, err
+	}
+
+	entries := []ReleaseEntry{}
+	for _, hit := range searchResp.Hits.Hits {
+		entries = append(entries, hit.Source)
+	}
+	return entries, searchResp.Hits.Total.Value, nil
+}
+
+// searchRevisions lists an article's revisions newest first, only the given one when revision > 0.
+// Unless complete is set the text and diff are left out to keep listings small.
+func searchRevisions(articleID string, revision, from, size int, complete bool) ([]Revision, int, error) {
+	filter := []map[string]interface{}{
+		{"term": map[string]interface{}{"article_id": articleID}},
+	}
+	if revision > 0 {
+		filter = append(filter, map[string]interface{}{"term": map[string]interface{}{"revision": revision}})
+	}
+
+	esQuery := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{"filter": filter},
+		},
+		"sort": []map[string]interface{}{{"revision": "desc"}},
+		"from": from,
+		"size": size,
+	}
+	if !complete {
+		esQuery["_source"] = map[string]interface{}{"excludes": []string{"text", "diff"}}
+	}
+
+	jsonData, err := json.Marshal(esQuery)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	esURL := getEnv("ELASTICSEARCH_URL", "http://localhost:9200")
+	indexName := getEnv("ELASTICSEARCH_REVISIONS_INDEX", "article-revisions")
+	resp, err := client.Post(fmt.Sprintf("%s/%s/_search", esURL, indexName), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	// The crawler creates the index with the first revision
+	if resp.StatusCode == http.StatusNotFound {
+		return []Revision{}, 0, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("elasticsearch returned status %d", resp.StatusCode)
+	}
+
+	var searchResp struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source Revision `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&searchResp); err != nil {
+		return nil, 0, err
+	}
+
+	revisions := []Revision{}
+	for _, hit := range searchResp.Hits.Hits {
+		revisions = append(revisions, hit.Source)
+	}
+	return revisions, searchResp.Hits.Total.Value, nil
+}
+
 func getAutocompleteSuggestions(query string) []string {
 	// Build a simple prefix query to get suggestions
 	esQuery := map[string]interface{}{
@@ -626,6 +862,7 @@ func getAutocompleteSuggestions(query string) []string {
 						},
 					},
 				},
+				"must_not": search.ExcludeDeletedArticles(),
 			},
 		},
 		"size":    5,
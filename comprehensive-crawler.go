@@ -1,29 +1,211 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
+	"flag"
 	"fmt"
-	"math/rand"
+	"hash/fnv"
+	"io"
+	"math/bits"
+	"mime"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
 	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
+	"unicode"
 
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
 	"github.com/gocolly/colly/v2"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"gopkg.in/yaml.v3"
+
+	"release-crawler/internal/polite"
+	"release-crawler/internal/sink"
+)
+
+// The article model is shared with the sinks that write it, see internal/sink
+type (
+	Article      = sink.Article
+	Translation  = sink.Translation
+	Image        = sink.Image
+	Attachment   = sink.Attachment
+	Link         = sink.Link
+	Passage      = sink.Passage
+	ReleaseEntry = sink.ReleaseEntry
 )
 
-type Article struct {
-	ID        string `json:"id"`
+// ArticleSource finds the articles of a help center and fetches them one at a time. Discover
+// returns every article URL with its last modification time plus per-part failures that make
+// the list incomplete; only an error means discovery failed outright.
+type ArticleSource interface {
+	Name() string
+	Discover() ([]URL, []error, error)
+	Fetch(articleURL string, cached CacheValidators) (*Article, CacheValidators, error)
+}
+
+// HTMLSource discovers articles through the sitemap and scrapes their HTML pages
+type HTMLSource struct {
+	sitemapURL string
+	config     Config
+
+	mu         sync.Mutex
+	lastMod    map[string]string // sitemap lastmod per article URL, the fallback for updated_at
+	discovered map[string]bool   // document IDs listed by the sitemap, to check translations against
+}
+
+// LinkCrawlSource discovers articles by following links from seed pages, for sites without a
+// sitemap, and scrapes them like HTMLSource
+type LinkCrawlSource struct {
+	*HTMLSource
+	seeds    []string
+	scope    []string // URL prefixes a link must start with to be followed
+	maxDepth int      // clicks away from a seed
+	maxPages int
+	client   *http.Client
+
+	// Article pages as Discover downloaded them, so Fetch doesn't request them again; guarded
+	// by the HTMLSource's mu and dropped once used
+	kept      map[string]*keptPage
+	keptBytes int64
+}
+
+// keptPage is an article page the link crawl already downloaded. It stands in for the server
+// when the page is scraped, answering a conditional request the way the server would have.
+type keptPage struct {
+	header http.Header
+	body   []byte
+}
+
+// maxKeptBytes caps the article pages the link crawl holds on to; pages past it are downloaded
+// again by Fetch
+const maxKeptBytes = 256 << 20
+
+// crawlTarget is a page queued by the link crawl, depth clicks away from a seed
+type crawlTarget struct {
+	url   string
+	depth int
+	seed  bool
+}
+
+// crawledPage is what the link crawl reads from one page
+type crawledPage struct {
+	canonical    string   // rel=canonical, when the page declares one in scope
+	links        []string // canonical URLs of every link on the page
+	next         string   // rel=next pagination link
+	lastModified string   // Last-Modified header, the change signal in place of a sitemap lastmod
+	page         *keptPage
+	err          error
+}
+
+// ZendeskAPISource reads articles, sections and categories from the Zendesk Help Center API,
+// which has the real section and timestamps the HTML pages don't expose reliably
+type ZendeskAPISource struct {
+	baseURL  string
+	locales  []string
+	email    string
+	apiToken string
+	config   Config
+	client   *http.Client
+
+	mu           sync.Mutex
+	listed       map[string]*zendeskArticle // by html_url, filled by Discover
+	translations map[int64][]Translation    // every listed locale of an article, by Zendesk ID
+	sections     map[localizedID]ZendeskSection
+	categories   map[localizedID]ZendeskCategory
+}
+
+// localizedID keys sections and categories, whose IDs are shared by every locale but whose
+// names are translated
+type localizedID struct {
+	locale string
+	id     int64
+}
+
+type zendeskArticle struct {
+	ID        int64  `json:"id"`
+	HTMLURL   string `json:"html_url"`
 	Title     string `json:"title"`
 	Body      string `json:"body"`
-	URL       string `json:"url"`
+	Locale    string `json:"locale"`
+	SectionID int64  `json:"section_id"`
+	Draft     bool   `json:"draft"`
+	Archived  bool   `json:"archived"` // incremental export only
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
 }
 
+type ZendeskSection struct {
+	ID              int64  `json:"id"`
+	Name            string `json:"name"`
+	CategoryID      int64  `json:"category_id"`
+	ParentSectionID int64  `json:"parent_section_id"`
+	HTMLURL         string `json:"html_url"`
+}
+
+type ZendeskCategory struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	HTMLURL string `json:"html_url"`
+}
+
+// IncrementalSource only lists articles changed since a saved cursor. Its listing can't be used
+// to find deleted articles, so it reports the ones unpublished since the cursor through Removed,
+// and the cursor is committed only once the changes are safely indexed.
+type IncrementalSource interface {
+	ArticleSource
+	Removed() []IndexedDocument
+	CommitCursor() error
+}
+
+// ZendeskIncrementalSource pulls articles changed since the last successful run from the
+// Help Center incremental export, keyed by a start_time cursor persisted between runs
+type ZendeskIncrementalSource struct {
+	*ZendeskAPISource
+	cursorPath string
+	fullExport bool
+	endTime    int64             // cursor to commit after a successful run
+	removed    []IndexedDocument // drafted or archived since the cursor, filled by Discover
+}
+
+// ZendeskCursor is the persisted position in the incremental export
+type ZendeskCursor struct {
+	StartTime int64     `json:"start_time"`
+	SavedAt   time.Time `json:"saved_at"`
+}
+
+// zendeskPage is one page of a Help Center list endpoint; only the list matching the endpoint is set
+type zendeskPage struct {
+	Articles   []zendeskArticle  `json:"articles"`
+	Sections   []ZendeskSection  `json:"sections"`
+	Categories []ZendeskCategory `json:"categories"`
+	NextPage   string            `json:"next_page"`
+	Count      int               `json:"count"`
+	EndTime    int64             `json:"end_time"` // incremental export only
+}
+
 type Sitemap struct {
 	XMLName xml.Name `xml:"urlset"`
 	URLs    []URL    `xml:"url"`
@@ -34,524 +216,5353 @@ type URL struct {
 	LastMod string `xml:"lastmod"`
 }
 
+// SitemapIndex is a <sitemapindex> document pointing at child sitemaps
+type SitemapIndex struct {
+	XMLName  xml.Name       `xml:"sitemapindex"`
+	Sitemaps []SitemapChild `xml:"sitemap"`
+}
+
+type SitemapChild struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// sitemapCrawler walks a sitemap tree, de-duplicating article URLs across child sitemaps
+type sitemapCrawler struct {
+	client   *http.Client
+	robots   *polite.RobotsPolicy
+	limiter  *polite.RateLimiter
+	visited  map[string]bool
+	seen     map[string]int
+	urls     []URL
+	failures []error
+}
+
 type Config struct {
 	FetchConcurrency int
 	FetchDelay       time.Duration
 	MaxRetries       int
 	RequestTimeout   time.Duration
+	Robots           *polite.RobotsPolicy
+	Limiter          *polite.RateLimiter
+	Assets           *AssetFetcher
+	Locales          []string     // help center locales to crawl, the first one is the primary
+	Profile          *SiteProfile // extraction rules of the HTML source
+	Site             *Site        // the site being crawled, nil for settings shared by every site
+}
+
+// Site is one documentation site in the index. SITES_FILE lists several; without it the crawler
+// indexes the single site configured by SITEMAP_URL, ARTICLE_SOURCE and SITE_PROFILE.
+type Site struct {
+	Name       string   `yaml:"name"`        // indexed as source, defaults to the sitemap's host name
+	Source     string   `yaml:"source"`      // auto, html, zendesk or zendesk-incremental, as ARTICLE_SOURCE
+	Sitemap    string   `yaml:"sitemap"`     // as SITEMAP_URL
+	ZendeskURL string   `yaml:"zendesk_url"` // defaults to the sitemap's host
+	Profile    string   `yaml:"profile"`     // site profile file, defaults to SITE_PROFILE
+	Locales    []string `yaml:"locales"`     // defaults to CRAWL_LOCALES
+	Rate       float64  `yaml:"rate"`        // requests per second per host, defaults to CRAWL_RATE
+	// Link crawl (source: crawl) for sites without a sitemap: the pages to start from, the URL
+	// prefixes links must start with to be followed (default: the seeds' hosts), how many clicks
+	// away from a seed to go and how many pages to visit at most
+	Seeds    []string `yaml:"seeds"`
+	Scope    []string `yaml:"scope"`
+	MaxDepth int      `yaml:"max_depth"`
+	MaxPages int      `yaml:"max_pages"`
+	// Names of the environment variables holding the site's Zendesk API credentials, so the
+	// sites file itself holds no secrets
+	ZendeskEmailEnv string `yaml:"zendesk_email_env"`
+	ZendeskTokenEnv string `yaml:"zendesk_token_env"`
+	// How to sign in to pages only shown to signed-in users, none when unset
+	Auth *SiteAuth `yaml:"auth"`
+	// Prepended to the site's document IDs, since two sites can use the same article IDs.
+	// Defaults to the name and a dash; only an explicit "" leaves the IDs plain.
+	IDPrefix *string `yaml:"id_prefix"`
+
+	profile *SiteProfile
+	hosts   []string
+	session *siteSession
+}
+
+// SiteAuth is how the crawler signs in to a site's gated pages. Like the Zendesk credentials,
+// the values are never written in the sites file: each *_env field names the environment
+// variable, or the key in the secrets file, holding them.
+type SiteAuth struct {
+	Type          string `yaml:"type"`           // basic, bearer, cookie or form
+	UsernameEnv   string `yaml:"username_env"`   // basic and form, defaults to CRAWL_USERNAME
+	PasswordEnv   string `yaml:"password_env"`   // basic and form, defaults to CRAWL_PASSWORD
+	TokenEnv      string `yaml:"token_env"`      // bearer, defaults to CRAWL_TOKEN
+	CookieEnv     string `yaml:"cookie_env"`     // cookie: "name=value; …" of a signed-in browser, defaults to CRAWL_COOKIE
+	LoginURL      string `yaml:"login_url"`      // form: the page with the login form
+	UsernameField string `yaml:"username_field"` // form: defaults to the form's email or text input
+	PasswordField string `yaml:"password_field"` // form: defaults to the form's password input
+}
+
+// siteSession is what requests to a signed-in site carry: an Authorization header, or the
+// cookies of the session in a jar shared by every request to the site
+type siteSession struct {
+	auth      *SiteAuth
+	header    string
+	jar       http.CookieJar
+	client    *http.Client // login requests, filling the jar
+	userAgent string
+	username  string
+	password  string
+
+	mu     sync.Mutex
+	logins int // form logins so far: the first one, then one per expired session
+}
+
+// maxSessionRenewals caps the logins after the first, so a session the site keeps rejecting
+// doesn't turn every article into a login attempt
+const maxSessionRenewals = 3
+
+// AuthRequiredError is returned for pages that answer with a login wall instead of the page:
+// a redirect to the site's sign-in page, a 401, or a login form where the article should be
+type AuthRequiredError struct {
+	URL    string
+	Reason string
+}
+
+func (e *AuthRequiredError) Error() string {
+	return fmt.Sprintf("login required: %s", e.Reason)
+}
+
+// MultiSiteSource crawls several sites as one: it lists the articles of every site and hands
+// each URL to the source of the site that listed it
+type MultiSiteSource struct {
+	sites   []*Site
+	sources []ArticleSource
+
+	mu    sync.Mutex
+	owner map[string]ArticleSource // by article URL, filled by Discover
+}
+
+//go:embed profiles/zendesk.yaml
+var defaultSiteProfileYAML []byte
+
+// SiteProfile holds the extraction rules for one documentation site, read from a YAML or JSON
+// file (SITE_PROFILE). Keys missing from the file keep the values of the built-in Zendesk
+// profile, profiles/zendesk.yaml, which documents each of them.
+type SiteProfile struct {
+	Name           string   `yaml:"name"`
+	Include        []string `yaml:"include"` // article URL patterns
+	Exclude        []string `yaml:"exclude"`
+	IDPattern      string   `yaml:"id_pattern"` // first capture group is the article ID
+	Title          []string `yaml:"title"`      // selectors, tried in order
+	TitleSkipLines []string `yaml:"title_skip_lines"`
+	IgnoreTitles   []string `yaml:"ignore_titles"`
+	Body           []string `yaml:"body"`
+	Strip          []string `yaml:"strip"` // removed from the body
+	Breadcrumbs    []string `yaml:"breadcrumbs"`
+	Dates          []string `yaml:"dates"`
+	DateText       []string `yaml:"date_text"`
+	LoginURLs      []string `yaml:"login_urls"` // sign-in pages gated articles redirect to
+	LoginForm      []string `yaml:"login_form"` // selectors marking a login page
+
+	include   []*regexp.Regexp
+	exclude   []*regexp.Regexp
+	idPattern *regexp.Regexp
+	loginURLs []*regexp.Regexp
 }
 
 type FetchResult struct {
-	Article *Article
-	Error   error
-	URL     string
-	Retries int
+	Article     *Article
+	Error       error
+	URL         string
+	Retries     int
+	NotModified bool
+	Validators  CacheValidators
 }
 
-type ElasticsearchConfig struct {
-	Enabled  bool
-	URL      string
-	Index    string
-	Username string
-	Password string
+// CacheValidators are the response headers we echo back to ask "has this changed since?"
+type CacheValidators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// errNotModified is what scrapeFullArticle returns when the server answered 304
+var errNotModified = polite.ErrNotModified
+
+// articleOutput is the process's real stdout, which the stdout sink keeps to itself while the
+// crawl log moves to stderr (see main)
+var articleOutput = os.Stdout
+
+// ReconcileSummary describes what the reconciliation phase removed from the index
+type ReconcileSummary struct {
+	Mode     string
+	Indexed  int
+	Removed  []IndexedDocument
+	Failures []error
+}
+
+// IndexedDocument is the subset of an indexed article needed to decide whether it is stale
+type IndexedDocument struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// CrawlStateEntry records what we knew about an article URL after its last successful fetch
+type CrawlStateEntry struct {
+	// ID is the document ID the article was last written under, see MovedIDs
+	ID          string    `json:"id,omitempty"`
+	LastMod     string    `json:"lastmod"`
+	ContentHash string    `json:"content_hash"`
+	LastFetched time.Time `json:"last_fetched"`
+	CacheValidators
+	// Links found on the last fetch, so unchanged articles still count in the link graph
+	InternalLinks []Link `json:"internal_links,omitempty"`
+	ExternalLinks []Link `json:"external_links,omitempty"`
+	// LinkedFrom is the in-links last stored in the index, so unchanged ones aren't re-sent
+	LinkedFrom []string `json:"linked_from,omitempty"`
+	SimHash    string   `json:"simhash,omitempty"`
+	// DuplicateOf is the canonical article last stored in the index, empty when it is canonical
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	// Revision and RevisionHash identify the latest snapshot in the revisions index, so an
+	// unchanged article needs no lookup there
+	Revision     int    `json:"revision,omitempty"`
+	RevisionHash string `json:"revision_hash,omitempty"`
+}
+
+// CrawlState is the local store used to skip articles that haven't changed since the previous run
+type CrawlState struct {
+	mu   sync.Mutex
+	path string
+	// Version is the document format the entries were indexed with, see crawlStateVersion
+	Version int                         `json:"version,omitempty"`
+	Entries map[string]*CrawlStateEntry `json:"entries"`
+}
+
+// crawlStateVersion is bumped whenever indexed documents gain fields that only a re-fetch fills in.
+// A state from an older version triggers one full crawl. 2: body_markdown and body_text, 3: passages,
+// 4: images, attachments and links, 5: links kept in the state for the link graph, 6: simhash,
+// 7: locale, translations and per-language fields, 8: source and site, 9: document IDs in the state.
+const crawlStateVersion = 9
+
+// Checkpoint is an append-only log of URLs finished by the current run, used by --resume
+type Checkpoint struct {
+	mu        sync.Mutex
+	path      string
+	file      *os.File
+	completed map[string]bool
+	failed    map[string]string
+	pending   []checkpointRecord
 }
 
-type ProcessorFunc func(*Article) error
+type checkpointRecord struct {
+	URL    string    `json:"url"`
+	Status string    `json:"status"` // completed, gone or failed
+	Error  string    `json:"error,omitempty"`
+	At     time.Time `json:"at"`
+}
 
 func main() {
+	resume := flag.Bool("resume", false, "skip URLs completed by the previous run and retry only the failed ones")
+	checkpointPath := flag.String("checkpoint", getEnv("CRAWL_CHECKPOINT_FILE", "crawl-checkpoint.jsonl"), "file recording completed and failed URLs")
+	profilePath := flag.String("profile", getEnv("SITE_PROFILE", ""), "YAML or JSON site profile with the extraction rules (default: the built-in Zendesk profile)")
+	sitesPath := flag.String("sites", getEnv("SITES_FILE", ""), "YAML or JSON file listing the sites to crawl into the index (default: the single site from SITEMAP_URL)")
+	secretsPath := flag.String("secrets", getEnv("CRAWL_SECRETS_FILE", ""), "YAML or JSON file of credentials the sites' auth and Zendesk settings name, for those not set in the environment")
+	validateURL := flag.String("validate", "", "scrape one article URL with its site's profile, print what was extracted and exit")
+	flag.Parse()
+
+	// With the stdout sink, stdout carries nothing but articles: every log line, including the
+	// packages' ones, goes to stderr
+	if slices.ContainsFunc(strings.Split(getEnv("CRAWL_SINKS", ""), ","), func(name string) bool {
+		return strings.EqualFold(strings.TrimSpace(name), "stdout")
+	}) {
+		os.Stdout = os.Stderr
+	}
+
+	if *secretsPath != "" {
+		secrets, err := loadSecrets(*secretsPath)
+		if err != nil {
+			fmt.Printf("❌ Error loading secrets: %v\n", err)
+			os.Exit(1)
+		}
+		crawlSecrets = secrets
+	}
+
+	locales := parseLocales(getEnv("CRAWL_LOCALES", getEnv("ZENDESK_LOCALE", "en-us")))
+	sites, err := loadSites(*sitesPath, *profilePath, locales)
+	if err != nil {
+		fmt.Printf("❌ Error loading sites: %v\n", err)
+		os.Exit(1)
+	}
+	// Article IDs read from URLs, including link targets and translations, follow the URL's site
+	crawlSites = sites
+
 	config := Config{
 		FetchConcurrency: 15,
 		FetchDelay:       200 * time.Millisecond,
 		MaxRetries:       3,
 		RequestTimeout:   30 * time.Second,
+		Locales:          locales,
+		Robots: polite.NewRobotsPolicy(
+			getEnvBool("RESPECT_ROBOTS_TXT", true),
+			getEnv("ROBOTS_USER_AGENT", "release-crawler"),
+			time.Duration(getEnvInt("MAX_CRAWL_DELAY_SECONDS", 30))*time.Second,
+		),
+	}
+	config.Limiter = polite.NewRateLimiter(
+		getEnvFloat("CRAWL_RATE", 5),
+		getEnvFloat("CRAWL_MIN_RATE", 0.2),
+		time.Duration(getEnvInt("MAX_RETRY_AFTER_SECONDS", 300))*time.Second,
+		config.Robots,
+	)
+	for _, site := range sites {
+		if site.Rate > 0 {
+			for _, host := range site.hosts {
+				config.Limiter.SetRate(host, site.Rate)
+			}
+		}
+	}
+
+	// A site that can't be signed in to is still crawled anonymously; its gated articles are
+	// then reported as auth failures
+	for _, site := range sites {
+		if site.Auth == nil {
+			continue
+		}
+		if err := site.signIn(config); err != nil {
+			fmt.Printf("🔒 Could not sign in to %s: %v\n", site.Name, err)
+			continue
+		}
+		fmt.Printf("🔑 Signed in to %s (%s auth)\n", site.Name, site.Auth.Type)
+	}
+	config.Assets = NewAssetFetcher(
+		getEnvBool("ATTACHMENT_HEAD", true),
+		getEnv("MIRROR_DIR", ""),
+		int64(getEnvInt("MIRROR_MAX_BYTES", 50*1024*1024)),
+		config,
+	)
+
+	if *validateURL != "" {
+		// A page on a host no site serves yet is checked against the first site's profile
+		site := siteForURL(*validateURL)
+		if site == nil {
+			site = sites[0]
+		}
+		if !validateProfile(*validateURL, site.Config(config)) {
+			os.Exit(1)
+		}
+		return
 	}
 
 	fmt.Println("🌍 Starting comprehensive Talkdesk documentation crawler...")
 
-	// Phase 1: Get all URLs from sitemap
-	fmt.Println("📋 Fetching sitemap...")
-	articleURLs, err := fetchSitemapURLs()
+	// Load crawl state from the previous run so unchanged articles can be skipped
+	state, err := loadCrawlState(getEnv("CRAWL_STATE_FILE", "crawl-state.json"))
+	if err != nil {
+		fmt.Printf("❌ Error loading crawl state: %v\n", err)
+		return
+	}
+	fullCrawl := getEnvBool("FULL_CRAWL", false)
+	if state.Outdated() {
+		fmt.Println("♻ Documents were indexed with an older format, re-fetching every article once")
+		fullCrawl = true
+	}
+
+	// A site whose id_prefix changed has its articles written again under the new IDs, even
+	// unchanged ones; the old documents are only reconciled away once that worked
+	movedFrom := state.MovedIDs()
+	if len(movedFrom) > 0 {
+		fmt.Printf("🔀 %d articles have a new document ID (id_prefix changed), writing them again\n", len(movedFrom))
+	}
+
+	source, err := selectSources(sites, config)
+	if err != nil {
+		fmt.Printf("❌ Error selecting article source: %v\n", err)
+		return
+	}
+	if incremental, ok := source.(*ZendeskIncrementalSource); ok && (fullCrawl || len(movedFrom) > 0) {
+		incremental.fullExport = true
+	}
+
+	// Phase 1: Discover every article and its last modification time
+	if len(sites) == 1 {
+		fmt.Printf("📋 Discovering articles via the %s source...\n", source.Name())
+	}
+	filteredURLs, discoveryFailures, err := source.Discover()
+	if err != nil {
+		fmt.Printf("❌ Error discovering articles: %v\n", err)
+		return
+	}
+	if len(discoveryFailures) > 0 {
+		fmt.Printf("⚠ %d parts of the article listing could not be read, continuing with %d URLs\n", len(discoveryFailures), len(filteredURLs))
+	}
+	if len(sites) == 1 {
+		fmt.Printf("📄 Found %d articles in %s\n", len(filteredURLs), strings.Join(sites[0].Locales, ", "))
+	} else {
+		fmt.Printf("📄 Found %d articles on %d sites\n", len(filteredURLs), len(sites))
+	}
+
+	// Only re-fetch articles that are new or whose lastmod changed
+	lastModByURL := make(map[string]string, len(filteredURLs))
+	validatorsByURL := make(map[string]CacheValidators)
+	var articleURLs []string
+	for _, u := range filteredURLs {
+		lastModByURL[u.Loc] = u.LastMod
+		_, moved := movedFrom[u.Loc]
+		if fullCrawl || moved || state.NeedsFetch(u) {
+			articleURLs = append(articleURLs, u.Loc)
+			// A full crawl re-downloads everything, otherwise let the server answer 304 for unchanged pages
+			if !fullCrawl && !moved {
+				validatorsByURL[u.Loc] = state.Validators(u.Loc)
+			}
+		}
+	}
+	skipped := len(filteredURLs) - len(articleURLs)
+	fmt.Printf("🔁 %d articles new or changed, %d unchanged since last crawl\n", len(articleURLs), skipped)
+
+	checkpoint, err := openCheckpoint(*checkpointPath, *resume)
 	if err != nil {
-		fmt.Printf("❌ Error fetching sitemap: %v\n", err)
+		fmt.Printf("❌ Error opening checkpoint: %v\n", err)
 		return
 	}
+	defer checkpoint.Close()
 
-	// Filter to only English article URLs
-	filteredURLs := filterEnglishArticles(articleURLs)
-	fmt.Printf("📄 Found %d English articles to crawl\n", len(filteredURLs))
+	resumedURLs := 0
+	if *resume {
+		articleURLs, resumedURLs = checkpoint.Remaining(articleURLs)
+		fmt.Printf("⏯  Resuming: %d URLs already completed, %d left (including %d previously failed)\n",
+			resumedURLs, len(articleURLs), checkpoint.FailedCount())
+	}
 
 	// Phase 2: Setup Elasticsearch
-	esConfig := ElasticsearchConfig{
-		Enabled: getEnvBool("ELASTICSEARCH_ENABLED", true),
-		URL:     getEnv("ELASTICSEARCH_URL", "http://localhost:9200"),
-		Index:   getEnv("ELASTICSEARCH_INDEX", "documentation-articles"),
+	esConfig := sink.ElasticsearchConfig{
+		Enabled:        getEnvBool("ELASTICSEARCH_ENABLED", true),
+		URL:            getEnv("ELASTICSEARCH_URL", "http://localhost:9200"),
+		Index:          getEnv("ELASTICSEARCH_INDEX", "documentation-articles"),
+		ReleasesIndex:  getEnv("ELASTICSEARCH_RELEASES_INDEX", "release-entries"),
+		RevisionsIndex: getEnv("ELASTICSEARCH_REVISIONS_INDEX", "article-revisions"),
+		BulkSize:       getEnvInt("ELASTICSEARCH_BULK_SIZE", 200),
+		BulkBytes:      getEnvInt("ELASTICSEARCH_BULK_BYTES", 5*1024*1024),
+		FlushInterval:  time.Duration(getEnvInt("ELASTICSEARCH_FLUSH_SECONDS", 5)) * time.Second,
+		MaxRetries:     getEnvInt("ELASTICSEARCH_MAX_RETRIES", 3),
+	}
+	if !getEnvBool("RELEASE_ENTRIES", true) {
+		esConfig.ReleasesIndex = ""
+	}
+	if !getEnvBool("REVISION_HISTORY", true) {
+		esConfig.RevisionsIndex = ""
 	}
 
-	var processors []ProcessorFunc
-	if esConfig.Enabled {
-		if err := createElasticsearchIndex(esConfig); err != nil {
-			fmt.Printf("⚠ Failed to create Elasticsearch index: %v\n", err)
+	sinks := sink.OpenAll(buildSinks(esConfig, state))
+	var esSink *sink.ElasticsearchSink
+	for _, s := range sinks {
+		if es, ok := s.(*sink.ElasticsearchSink); ok {
+			esSink = es
 		}
-		processors = append(processors, createElasticsearchProcessor(esConfig))
 	}
 
+	// Stop handing out new URLs on Ctrl+C/SIGTERM, but let in-flight articles finish so
+	// sinks, crawl state and checkpoint are flushed before exiting
+	stop := make(chan struct{})
+	interrupted := false
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		fmt.Println("\n🛑 Interrupt received, finishing in-flight articles and saving checkpoint (press Ctrl+C again to force quit)")
+		close(stop)
+		<-signals
+		os.Exit(1)
+	}()
+
 	// Phase 3: Crawl all articles concurrently
 	fmt.Println("🚀 Starting concurrent article crawling...")
-	results := fetchArticlesConcurrently(filteredURLs, config)
+	results := fetchArticlesConcurrently(source, articleURLs, validatorsByURL, config, stop)
+	checkpointEvery := getEnvInt("CHECKPOINT_EVERY", 25)
+	processed := 0
 
 	// Process results
 	var successfulArticles []Article
-	var errors []error
+	var fetchErrors []error
+	unchangedContent := 0
+	notModified := 0
+	robotsBlocked := 0
+	authFailures := 0
+	goneIDs := make(map[string]bool)
+	writtenIDs := make(map[string]bool)
+	var writeFailures []sink.Failure
+	handledFailures := make(map[sink.Sink]int) // per sink, see drainFailures
 
 	for result := range results {
 		if result.Error != nil {
-			errors = append(errors, fmt.Errorf("failed to fetch %s after %d retries: %v", result.URL, result.Retries, result.Error))
+			var blocked *polite.RobotsBlockedError
+			var authErr *AuthRequiredError
+			if errors.As(result.Error, &blocked) {
+				// Already logged with the rule that blocked it when the source skipped it
+				robotsBlocked++
+				checkpoint.Mark(result.URL, "blocked", result.Error)
+			} else if isGoneError(result.Error) {
+				// 404/410 means the article was removed upstream, reconciliation drops it from the index
+				fmt.Printf("🗑  Gone: %s (%v)\n", result.URL, result.Error)
+				goneIDs[articleIDFromURL(result.URL)] = true
+				state.Forget(result.URL)
+				checkpoint.Mark(result.URL, "gone", nil)
+			} else if errors.As(result.Error, &authErr) {
+				// A login wall, not a broken page: the site's auth is missing, wrong or expired
+				fmt.Printf("🔒 Auth failure: %s (%s)\n", result.URL, authErr.Reason)
+				authFailures++
+				fetchErrors = append(fetchErrors, fmt.Errorf("auth failure for %s: %v", result.URL, result.Error))
+				checkpoint.Mark(result.URL, "failed", result.Error)
+			} else {
+				fetchErrors = append(fetchErrors, fmt.Errorf("failed to fetch %s after %d retries: %v", result.URL, result.Retries, result.Error))
+				checkpoint.Mark(result.URL, "failed", result.Error)
+			}
+		} else if result.NotModified {
+			// 304: the server vouches the page is unchanged, nothing to parse or re-index
+			notModified++
+			state.Touch(result.URL, lastModByURL[result.URL])
+			checkpoint.Mark(result.URL, "completed", nil)
 		} else {
 			successfulArticles = append(successfulArticles, *result.Article)
 			fmt.Printf("✓ Fetched: %s\n", result.Article.Title)
 
-			// Apply processors (e.g., Elasticsearch indexing)
-			for _, processor := range processors {
-				if err := processor(result.Article); err != nil {
-					fmt.Printf("⚠ Processor error for %s: %v\n", result.Article.Title, err)
-				}
-			}
-		}
+			// lastmod moved but the content is identical, so there is nothing to re-index
+			if !state.Changed(result.URL, result.Article) && !fullCrawl {
+				unchangedContent++
+				state.Record(result.URL, lastModByURL[result.URL], result.Article, result.Validators)
+				checkpoint.Mark(result.URL, "completed", nil)
+			} else {
+				// Fan the article out to every configured sink; a failed write fails the article
+				// right away, like a rejected bulk item at the next save
+				writtenIDs[result.Article.ID] = true
+				written := true
+				for _, s := range sinks {
+					if err := s.Write(result.Article); err != nil {
+						fmt.Printf("⚠ %s sink error for %s: %v\n", s.Name(), result.Article.Title, err)
+						writeFailures = append(writeFailures, sink.Failure{Sink: s.Name(), ID: result.Article.ID, URL: result.URL, Title: result.Article.Title, Reason: err.Error()})
+						written = false
+					}
+				}
+				// The new hash is only remembered once the sinks accepted the article, otherwise the
+				// next run would take it for unchanged and never write it; a document a sink rejects
+				// when flushing is forgotten again before the state is saved, see saveProgress
+				if written {
+					state.Record(result.URL, lastModByURL[result.URL], result.Article, result.Validators)
+					checkpoint.Mark(result.URL, "completed", nil)
+				} else {
+					state.Forget(result.URL)
+					checkpoint.Mark(result.URL, "failed", errors.New("sink write failed"))
+				}
+			}
+		}
+
+		// Periodically make progress durable: flush sinks and fail what they rejected first so a
+		// URL is only checkpointed as completed once its document has actually been written
+		processed++
+		if processed%checkpointEvery == 0 {
+			saveProgress(sinks, handledFailures, state, checkpoint)
+			fmt.Printf("⏱  %d/%d processed, effective rate: %s\n", processed, len(articleURLs), config.Limiter.Rates())
+		}
+	}
+
+	select {
+	case <-stop:
+		interrupted = true
+	default:
+	}
+
+	// Send whatever is still buffered before reconciling against the index, and fail the documents
+	// rejected since the last save
+	sinkFailures := append(writeFailures, sink.CloseAll(sinks)...)
+	failDocuments(drainFailures(sinks, handledFailures), state, checkpoint)
+
+	// Articles the export reported as drafted or archived leave the state here and the index in Phase 4
+	incremental, isIncremental := source.(IncrementalSource)
+	if isIncremental {
+		for _, doc := range incremental.Removed() {
+			state.Forget(doc.URL)
+		}
+	}
+	if err := checkpoint.Commit(); err != nil {
+		fmt.Printf("⚠ Failed to write checkpoint: %v\n", err)
+	}
+
+	// Only a run that re-fetched everything brings the whole state up to the current format
+	if fullCrawl && !interrupted && len(fetchErrors) == 0 {
+		state.MarkCurrent()
+	}
+	if err := state.Save(); err != nil {
+		fmt.Printf("⚠ Failed to save crawl state: %v\n", err)
+	}
+
+	// Phase 4: Remove articles that disappeared from the sitemap, now return 404/410 or were unpublished
+	var reconcile *ReconcileSummary
+	var removalErr error
+	reconcileMode := getEnv("RECONCILE_MODE", "delete")
+	switch {
+	case esSink == nil || reconcileMode == "off":
+		// Nothing to reconcile against
+	case isIncremental:
+		// The export only lists changes, so the articles it reported unpublished are all there is to
+		// remove; they are named explicitly, which makes it safe even on an interrupted run
+		if removed := incremental.Removed(); len(removed) > 0 {
+			fmt.Printf("🧹 Removing %d unpublished articles from Elasticsearch...\n", len(removed))
+			reconcile, removalErr = removeDocuments(esConfig, removed, reconcileMode)
+			if removalErr != nil {
+				fmt.Printf("⚠ Removing unpublished articles failed: %v\n", removalErr)
+			}
+		}
+	case interrupted || *resume:
+		// Only a complete, uninterrupted crawl knows which articles are really gone
+		fmt.Println("⚠ Skipping reconciliation: this run did not cover every article")
+	case len(discoveryFailures) > 0:
+		// A missing child sitemap would make every article it lists look deleted
+		fmt.Printf("⚠ Skipping reconciliation: %d parts of the article listing failed, the list is incomplete\n", len(discoveryFailures))
+	default:
+		liveIDs := make(map[string]bool, len(filteredURLs))
+		for _, u := range filteredURLs {
+			if id := articleIDFromURL(u.Loc); id != "" && !goneIDs[id] {
+				liveIDs[id] = true
+			}
+		}
+		// A moved article keeps its old document until the state records it under the new ID
+		for articleURL, oldID := range movedFrom {
+			if id := articleIDFromURL(articleURL); !goneIDs[id] && state.DocumentID(articleURL) != id {
+				liveIDs[oldID] = true
+			}
+		}
+
+		fmt.Println("🧹 Reconciling Elasticsearch index with current crawl...")
+		reconcile, err = reconcileIndex(esConfig, liveIDs, reconcileMode)
+		if err != nil {
+			fmt.Printf("⚠ Reconciliation failed: %v\n", err)
+		}
+	}
+
+	// Advance the export cursor only when every changed article made it into the sinks and every
+	// unpublished one out of the index, otherwise the next run exports the same window again
+	if isIncremental {
+		removalFailed := removalErr != nil || (reconcile != nil && len(reconcile.Failures) > 0)
+		if interrupted || len(fetchErrors) > 0 || len(sinkFailures) > 0 || removalFailed {
+			fmt.Println("⚠ Not advancing the export cursor: some changed articles were not indexed or removed")
+		} else if err := incremental.CommitCursor(); err != nil {
+			fmt.Printf("⚠ Failed to save export cursor: %v\n", err)
+		}
+	}
+
+	// Phase 5: Link graph, near-duplicates and broken links, over every live article including unchanged ones
+	var graph *LinkGraph
+	var brokenLinks []BrokenLink
+	var duplicates map[string]string
+	graphUpdated, duplicatesUpdated := 0, 0
+	duplicateDistance := getEnvInt("NEAR_DUPLICATE_DISTANCE", 3)
+	linkCheck := getEnv("LINK_CHECK", "internal")
+	brokenLinksReport := getEnv("BROKEN_LINKS_REPORT", "broken-links.csv")
+	switch {
+	case interrupted:
+		fmt.Println("⚠ Skipping link graph and duplicate detection: this run did not cover every article")
+	case len(discoveryFailures) > 0:
+		// Articles missing from the listing would lose their in-links and show up as broken targets
+		fmt.Println("⚠ Skipping link graph and duplicate detection: the article listing is incomplete")
+	default:
+		// The incremental export only lists changes, so every article in the state counts as live
+		var liveURLs map[string]bool
+		liveIDs := make(map[string]bool)
+		if !isIncremental {
+			liveURLs = make(map[string]bool, len(filteredURLs))
+			for _, u := range filteredURLs {
+				if id := articleIDFromURL(u.Loc); id != "" && !goneIDs[id] {
+					liveURLs[u.Loc] = true
+					liveIDs[id] = true
+				}
+			}
+		}
+		articles := state.ArticleLinks(liveURLs)
+		if isIncremental {
+			for _, article := range articles {
+				liveIDs[article.ID] = true
+			}
+		}
+
+		fmt.Println("🕸  Building the internal link graph...")
+		graph = buildLinkGraph(articles, liveIDs)
+		if esSink != nil {
+			var failures []sink.Failure
+			graphUpdated, failures = pushLinkGraph(esConfig, state, articles, graph, writtenIDs)
+			if len(failures) > 0 {
+				fmt.Printf("⚠ %d articles could not be updated with their in-links\n", len(failures))
+			}
+		}
+
+		if duplicateDistance >= 0 {
+			fmt.Println("👯 Looking for near-duplicate articles...")
+			fingerprints := state.Fingerprints(liveURLs)
+			duplicates = findNearDuplicates(fingerprints, duplicateDistance)
+			if esSink != nil {
+				var failures []sink.Failure
+				duplicatesUpdated, failures = pushDuplicates(esConfig, state, fingerprints, duplicates, writtenIDs)
+				if len(failures) > 0 {
+					fmt.Printf("⚠ %d articles could not be updated with their canonical article\n", len(failures))
+				}
+			}
+		}
+
+		if linkCheck != "off" {
+			fmt.Println("🔗 Checking links for broken targets...")
+			brokenLinks = findBrokenLinks(articles, liveIDs, goneIDs, NewLinkChecker(config), linkCheck == "all")
+			if err := writeBrokenLinkReport(brokenLinksReport, brokenLinks); err != nil {
+				fmt.Printf("⚠ Failed to write broken link report: %v\n", err)
+			}
+		}
+
+		if err := state.Save(); err != nil {
+			fmt.Printf("⚠ Failed to save crawl state: %v\n", err)
+		}
+	}
+
+	fmt.Printf("\n=== Summary ===\n")
+	fmt.Printf("Article source: %s\n", source.Name())
+	fmt.Printf("Discovery failures: %d (child sitemaps or listing pages)\n", len(discoveryFailures))
+	fmt.Printf("Effective rate: %s\n", config.Limiter.Rates())
+	fmt.Printf("Skipped (unchanged lastmod): %d articles\n", skipped)
+	if *resume {
+		fmt.Printf("Skipped (completed before resume): %d articles\n", resumedURLs)
+	}
+	fmt.Printf("Successfully fetched: %d articles\n", len(successfulArticles))
+	if len(sites) > 1 {
+		perSource := make(map[string]int)
+		for _, article := range successfulArticles {
+			perSource[article.Source]++
+		}
+		var counts []string
+		for _, site := range sites {
+			counts = append(counts, fmt.Sprintf("%s %d", site.Name, perSource[site.Name]))
+		}
+		fmt.Printf("  by source: %s\n", strings.Join(counts, ", "))
+	}
+	var allLocales []string
+	for _, site := range sites {
+		for _, locale := range site.Locales {
+			if !slices.Contains(allLocales, locale) {
+				allLocales = append(allLocales, locale)
+			}
+		}
+	}
+	if len(allLocales) > 1 {
+		perLocale := make(map[string]int)
+		for _, article := range successfulArticles {
+			perLocale[article.Locale]++
+		}
+		var counts []string
+		for _, locale := range allLocales {
+			counts = append(counts, fmt.Sprintf("%s %d", locale, perLocale[locale]))
+		}
+		fmt.Printf("  by locale: %s\n", strings.Join(counts, ", "))
+	}
+	fmt.Printf("HTTP 200 (downloaded): %d articles\n", len(successfulArticles))
+	fmt.Printf("HTTP 304 (not modified): %d articles\n", notModified)
+	fmt.Printf("Fetched but content unchanged: %d articles\n", unchangedContent)
+	fmt.Printf("Gone upstream (404/410): %d articles\n", len(goneIDs))
+	fmt.Printf("Blocked by robots.txt: %d articles\n", robotsBlocked)
+	fmt.Printf("Failed: %d articles\n", len(fetchErrors))
+	if authFailures > 0 {
+		fmt.Printf("  behind a login wall (auth failures): %d\n", authFailures)
+	}
+	for _, s := range sinks {
+		if reporter, ok := s.(sink.Reporter); ok {
+			fmt.Printf("Written to %s: %d documents (%d failed)\n", s.Name(), reporter.Written(), len(reporter.Failures()))
+		}
+	}
+	if esSink != nil && esConfig.ReleasesIndex != "" {
+		fmt.Printf("Release entries indexed: %d\n", esSink.ReleaseEntries())
+	}
+	if esSink != nil && esConfig.RevisionsIndex != "" {
+		fmt.Printf("Revisions recorded: %d\n", esSink.Revisions())
+	}
+	if graph != nil {
+		fmt.Printf("Link graph: %d links, %d articles linked from others (%d documents updated)\n", graph.Edges, len(graph.InLinks), graphUpdated)
+		if duplicates != nil {
+			canonicals := make(map[string]bool)
+			for _, canonical := range duplicates {
+				canonicals[canonical] = true
+			}
+			fmt.Printf("Near-duplicates: %d articles copying %d others (%d documents updated)\n", len(duplicates), len(canonicals), duplicatesUpdated)
+		}
+		if linkCheck != "off" {
+			fmt.Printf("Broken links: %d (report: %s)\n", len(brokenLinks), brokenLinksReport)
+		}
+	}
+
+	if len(fetchErrors) > 0 && len(fetchErrors) <= 10 {
+		fmt.Printf("\nErrors:\n")
+		for _, err := range fetchErrors {
+			fmt.Printf("- %v\n", err)
+		}
+	} else if len(fetchErrors) > 10 {
+		fmt.Printf("\nShowing first 10 errors of %d total:\n", len(fetchErrors))
+		for _, err := range fetchErrors[:10] {
+			fmt.Printf("- %v\n", err)
+		}
+	}
+
+	if len(sinkFailures) > 0 {
+		fmt.Printf("\nSink failures:\n")
+		for i, failure := range sinkFailures {
+			if i == 10 {
+				fmt.Printf("- ... and %d more\n", len(sinkFailures)-10)
+				break
+			}
+			fmt.Printf("- [%s] %s %s: HTTP %d %s\n", failure.Sink, failure.ID, failure.Title, failure.Status, failure.Reason)
+		}
+	}
+
+	if reconcile != nil {
+		printReconcileSummary(reconcile)
+	}
+
+	if len(brokenLinks) > 0 {
+		fmt.Printf("\nBroken links:\n")
+		for i, link := range brokenLinks {
+			if i == 10 {
+				fmt.Printf("- ... and %d more in %s\n", len(brokenLinks)-10, brokenLinksReport)
+				break
+			}
+			fmt.Printf("- article %s → %s: %s\n", link.SourceID, link.LinkURL, link.Reason)
+		}
+	}
+
+	if interrupted {
+		fmt.Printf("\n⏸  Crawl interrupted. Progress saved to %s, continue with: go run comprehensive-crawler.go --resume\n", *checkpointPath)
+	}
+}
+
+// saveProgress flushes every sink and fails the documents they rejected, then persists the
+// checkpoint and crawl state. Articles are recorded as soon as they are handed to the sinks, so
+// without this a rejected document would be saved as completed and unchanged, and neither
+// --resume nor the next run would write it again.
+func saveProgress(sinks []sink.Sink, handled map[sink.Sink]int, state *CrawlState, checkpoint *Checkpoint) {
+	for _, s := range sinks {
+		if err := s.Flush(); err != nil {
+			fmt.Printf("⚠ Flush of %s sink failed: %v\n", s.Name(), err)
+		}
+	}
+	failDocuments(drainFailures(sinks, handled), state, checkpoint)
+	if err := checkpoint.Commit(); err != nil {
+		fmt.Printf("⚠ Failed to write checkpoint: %v\n", err)
+	}
+	if err := state.Save(); err != nil {
+		fmt.Printf("⚠ Failed to save crawl state: %v\n", err)
+	}
+}
+
+// drainFailures returns the failures the sinks reported since the last call; handled counts
+// each sink's failures returned so far
+func drainFailures(sinks []sink.Sink, handled map[sink.Sink]int) []sink.Failure {
+	var failures []sink.Failure
+	for _, s := range sinks {
+		if reporter, ok := s.(sink.Reporter); ok {
+			reported := reporter.Failures()
+			failures = append(failures, reported[handled[s]:]...)
+			handled[s] = len(reported)
+		}
+	}
+	return failures
+}
+
+// failDocuments forgets the documents sinks rejected so the next run fetches and writes them
+// again, and checkpoints their URLs as failed for --resume
+func failDocuments(failures []sink.Failure, state *CrawlState, checkpoint *Checkpoint) {
+	for _, failure := range failures {
+		state.Forget(failure.URL)
+		checkpoint.Mark(failure.URL, "failed", fmt.Errorf("%s sink: %s", failure.Sink, failure.Reason))
+	}
+}
+
+// Maximum nesting of sitemap indexes we follow, guards against loops and runaway trees
+const maxSitemapDepth = 5
+
+// Upper bound on a single (decompressed) sitemap; the protocol caps files at 50MB
+const maxSitemapBytes = 50 * 1024 * 1024
+
+// fetchSitemapURLs returns every article URL reachable from sitemapURL. Child sitemaps that
+// fail are returned as failures without aborting discovery; only a failing root is fatal.
+func fetchSitemapURLs(sitemapURL string, robots *polite.RobotsPolicy, limiter *polite.RateLimiter) ([]URL, []error, error) {
+	// Use optimized HTTP client with connection pooling
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+
+	crawler := &sitemapCrawler{
+		client:  client,
+		robots:  robots,
+		limiter: limiter,
+		visited: make(map[string]bool),
+		seen:    make(map[string]int),
+	}
+
+	if err := crawler.crawl(sitemapURL, 0); err != nil {
+		return nil, nil, err
+	}
+
+	return crawler.urls, crawler.failures, nil
+}
+
+func (s *sitemapCrawler) crawl(sitemapURL string, depth int) error {
+	if s.visited[sitemapURL] {
+		return nil
+	}
+	s.visited[sitemapURL] = true
+
+	data, err := s.download(sitemapURL)
+	if err != nil {
+		return err
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	root, err := firstStartElement(decoder)
+	if err != nil {
+		return fmt.Errorf("failed to parse XML sitemap from %s (got HTML?): %v", sitemapURL, err)
+	}
+
+	switch root.Name.Local {
+	case "sitemapindex":
+		var index SitemapIndex
+		if err := decoder.DecodeElement(&index, &root); err != nil {
+			return fmt.Errorf("failed to parse sitemap index from %s: %v", sitemapURL, err)
+		}
+		if depth >= maxSitemapDepth {
+			return fmt.Errorf("sitemap index %s nested deeper than %d levels", sitemapURL, maxSitemapDepth)
+		}
+
+		fmt.Printf("🗂  Sitemap index %s lists %d child sitemaps\n", sitemapURL, len(index.Sitemaps))
+		for _, child := range index.Sitemaps {
+			childURL := strings.TrimSpace(child.Loc)
+			if childURL == "" {
+				continue
+			}
+			// A broken child must not abort discovery of its siblings
+			if err := s.crawl(childURL, depth+1); err != nil {
+				s.failures = append(s.failures, err)
+				fmt.Printf("⚠ Skipping child sitemap: %v\n", err)
+			}
+		}
+
+	case "urlset":
+		var sitemap Sitemap
+		if err := decoder.DecodeElement(&sitemap, &root); err != nil {
+			return fmt.Errorf("failed to parse XML sitemap from %s: %v", sitemapURL, err)
+		}
+		s.add(sitemap.URLs)
+
+	default:
+		return fmt.Errorf("unexpected root element <%s> in sitemap %s", root.Name.Local, sitemapURL)
+	}
+
+	return nil
+}
+
+// download fetches a sitemap and transparently gunzips .xml.gz files
+func (s *sitemapCrawler) download(sitemapURL string) ([]byte, error) {
+	if allowed, rule := s.robots.Allowed(sitemapURL); !allowed {
+		fmt.Printf("🤖 Skipping sitemap %s: blocked by robots.txt rule %s\n", sitemapURL, rule)
+		return nil, &polite.RobotsBlockedError{URL: sitemapURL, Rule: rule}
+	}
+	req, err := http.NewRequest("GET", sitemapURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", s.robots.UserAgent())
+
+	s.limiter.Wait(sitemapURL)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sitemap from %s: %v", sitemapURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		statusErr := &polite.HTTPStatusError{
+			URL:        sitemapURL,
+			StatusCode: resp.StatusCode,
+			Message:    "sitemap request failed",
+			RetryAfter: polite.ParseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+		s.limiter.Report(sitemapURL, statusErr)
+		return nil, statusErr
+	}
+	s.limiter.Report(sitemapURL, nil)
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSitemapBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sitemap from %s: %v", sitemapURL, err)
+	}
+
+	// Sniff the gzip magic bytes rather than trusting the extension or Content-Type,
+	// servers label .xml.gz files inconsistently
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip sitemap %s: %v", sitemapURL, err)
+		}
+		defer gz.Close()
+
+		data, err = io.ReadAll(io.LimitReader(gz, maxSitemapBytes))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress sitemap %s: %v", sitemapURL, err)
+		}
+	}
+
+	return data, nil
+}
+
+// add merges URLs into the result, keeping the newest lastmod when a URL is listed twice
+func (s *sitemapCrawler) add(urls []URL) {
+	for _, u := range urls {
+		u.Loc = strings.TrimSpace(u.Loc)
+		u.LastMod = strings.TrimSpace(u.LastMod)
+		if u.Loc == "" {
+			continue
+		}
+
+		if i, exists := s.seen[u.Loc]; exists {
+			if u.LastMod > s.urls[i].LastMod {
+				s.urls[i].LastMod = u.LastMod
+			}
+			continue
+		}
+
+		s.seen[u.Loc] = len(s.urls)
+		s.urls = append(s.urls, u)
+	}
+}
+
+func firstStartElement(decoder *xml.Decoder) (xml.StartElement, error) {
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start, nil
+		}
+	}
+}
+
+// selectSources builds the article source of every site; several sites are crawled through one
+// MultiSiteSource
+func selectSources(sites []*Site, config Config) (ArticleSource, error) {
+	if len(sites) == 1 {
+		return selectArticleSource(sites[0].Config(config))
+	}
+
+	multi := &MultiSiteSource{sites: sites, owner: make(map[string]ArticleSource)}
+	for _, site := range sites {
+		source, err := selectArticleSource(site.Config(config))
+		if err != nil {
+			return nil, fmt.Errorf("site %s: %w", site.Name, err)
+		}
+		multi.sources = append(multi.sources, source)
+	}
+	return multi, nil
+}
+
+// selectArticleSource picks the site's source (ARTICLE_SOURCE for a single site): "html",
+// "zendesk", "zendesk-incremental", "crawl" (follow links from the seeds instead of reading a
+// sitemap), or "auto" (the default), which uses the Zendesk API when the help center answers it
+// and falls back to scraping HTML
+func selectArticleSource(config Config) (ArticleSource, error) {
+	site := config.Site
+	htmlSource := NewHTMLSource(site.Sitemap, config)
+	zendesk := NewZendeskAPISource(site.ZendeskURL, config.Locales, config)
+
+	switch site.Source {
+	case "html":
+		return htmlSource, nil
+	case "zendesk":
+		return zendesk, nil
+	case "zendesk-incremental":
+		return NewZendeskIncrementalSource(zendesk, getEnv("ZENDESK_CURSOR_FILE", "zendesk-cursor.json"), getEnvBool("FULL_CRAWL", false)), nil
+	case "crawl":
+		return NewLinkCrawlSource(htmlSource, site), nil
+	case "auto":
+		if err := zendesk.Probe(); err != nil {
+			fmt.Printf("ℹ Zendesk API not available at %s (%v), scraping HTML instead\n", site.ZendeskURL, err)
+			return htmlSource, nil
+		}
+		return zendesk, nil
+	default:
+		return nil, fmt.Errorf("unknown article source %q (use auto, zendesk, zendesk-incremental, html or crawl)", site.Source)
+	}
+}
+
+// crawlSites are the sites of this run, see siteForURL
+var crawlSites []*Site
+
+var siteNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// loadSites reads the sites file at path, or describes the single site configured through the
+// environment when path is empty. Sites without their own profile or locales use defaultProfile
+// and defaultLocales.
+func loadSites(path, defaultProfile string, defaultLocales []string) ([]*Site, error) {
+	var sites []*Site
+	if path == "" {
+		// The single site has kept plain document IDs since before sites existed
+		idPrefix := getEnv("SITE_ID_PREFIX", "")
+		sites = []*Site{{
+			Name:            getEnv("SITE_NAME", ""),
+			IDPrefix:        &idPrefix,
+			Source:          getEnv("ARTICLE_SOURCE", "auto"),
+			Sitemap:         getEnv("SITEMAP_URL", "https://support.talkdesk.com/hc/sitemap.xml"),
+			ZendeskURL:      getEnv("ZENDESK_URL", ""),
+			ZendeskEmailEnv: "ZENDESK_EMAIL",
+			ZendeskTokenEnv: "ZENDESK_API_TOKEN",
+			Seeds:           splitList(getEnv("CRAWL_SEEDS", "")),
+			Scope:           splitList(getEnv("CRAWL_SCOPE", "")),
+			MaxDepth:        getEnvInt("CRAWL_MAX_DEPTH", 0),
+			MaxPages:        getEnvInt("CRAWL_MAX_PAGES", 0),
+		}}
+		if authType := getEnv("CRAWL_AUTH", ""); authType != "" {
+			sites[0].Auth = &SiteAuth{Type: authType, LoginURL: getEnv("CRAWL_LOGIN_URL", "")}
+		}
+	} else {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var file struct {
+			Sites []*Site `yaml:"sites"`
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if len(file.Sites) == 0 {
+			return nil, fmt.Errorf("%s lists no sites", path)
+		}
+		sites = file.Sites
+	}
+
+	names := make(map[string]bool)
+	hostOwners := make(map[string]string)
+	prefixOwners := make(map[string]string)
+	for i, site := range sites {
+		if err := site.resolve(defaultProfile, defaultLocales); err != nil {
+			return nil, fmt.Errorf("site %d (%s): %w", i+1, site.Name, err)
+		}
+		if names[site.Name] {
+			return nil, fmt.Errorf("site name %q is used twice", site.Name)
+		}
+		names[site.Name] = true
+		// Two sites with one prefix could index different articles under the same ID
+		if owner, taken := prefixOwners[*site.IDPrefix]; taken {
+			return nil, fmt.Errorf("site %s: id_prefix %q is already used by site %s", site.Name, *site.IDPrefix, owner)
+		}
+		prefixOwners[*site.IDPrefix] = site.Name
+		// Articles are matched to their site by host, so a host can only belong to one site
+		for _, host := range site.hosts {
+			if owner, taken := hostOwners[host]; taken {
+				return nil, fmt.Errorf("site %s: host %s is already served by site %s", site.Name, host, owner)
+			}
+			hostOwners[host] = site.Name
+		}
+		// The export cursor and the reconciliation it skips cover a whole run, not one site
+		if site.Source == "zendesk-incremental" && len(sites) > 1 {
+			return nil, fmt.Errorf("site %s: zendesk-incremental can only be used when crawling a single site", site.Name)
+		}
+	}
+	return sites, nil
+}
+
+// resolve fills in a site's defaults and loads its profile. The ID prefix depends on the site
+// alone, never on its place in the sites file, so reordering the file keeps every document ID.
+func (s *Site) resolve(defaultProfile string, defaultLocales []string) error {
+	s.Source = strings.ToLower(s.Source)
+	if s.Source == "" {
+		s.Source = "auto"
+	}
+
+	// The site is known by its sitemap, or by its first seed when it is crawled by following links
+	origin := s.Sitemap
+	if s.Source == "crawl" {
+		if len(s.Seeds) == 0 {
+			return fmt.Errorf("seeds are required to crawl a site by following links")
+		}
+		origin = s.Seeds[0]
+	} else if s.Sitemap == "" {
+		return fmt.Errorf("sitemap is required")
+	}
+	originURL, err := url.Parse(origin)
+	if err != nil || originURL.Host == "" {
+		return fmt.Errorf("invalid URL %q", origin)
+	}
+
+	if s.Name == "" {
+		s.Name = originURL.Hostname()
+	}
+	if !siteNamePattern.MatchString(s.Name) {
+		return fmt.Errorf("name %q may only contain letters, digits, dots, dashes and underscores", s.Name)
+	}
+	if s.IDPrefix == nil {
+		idPrefix := s.Name + "-"
+		s.IDPrefix = &idPrefix
+	} else if *s.IDPrefix != "" && !siteNamePattern.MatchString(*s.IDPrefix) {
+		return fmt.Errorf("id_prefix %q may only contain letters, digits, dots, dashes and underscores", *s.IDPrefix)
+	}
+	if s.ZendeskURL == "" {
+		s.ZendeskURL = originURL.Scheme + "://" + originURL.Host
+	}
+	s.ZendeskURL = strings.TrimRight(s.ZendeskURL, "/")
+	if s.Locales == nil {
+		s.Locales = defaultLocales
+	} else {
+		s.Locales = parseLocales(strings.Join(s.Locales, ","))
+	}
+
+	if s.Profile == "" {
+		s.Profile = defaultProfile
+	}
+	if s.profile, err = loadSiteProfile(s.Profile); err != nil {
+		return err
+	}
+
+	if s.Source == "crawl" {
+		for i, seed := range s.Seeds {
+			if s.Seeds[i] = canonicalURL(nil, seed); s.Seeds[i] == "" {
+				return fmt.Errorf("invalid seed URL %q", seed)
+			}
+		}
+		if len(s.Scope) == 0 {
+			for _, seed := range s.Seeds {
+				seedURL, _ := url.Parse(seed)
+				if prefix := seedURL.Scheme + "://" + seedURL.Host + "/"; !slices.Contains(s.Scope, prefix) {
+					s.Scope = append(s.Scope, prefix)
+				}
+			}
+		}
+		if s.MaxDepth <= 0 {
+			s.MaxDepth = 3
+		}
+		if s.MaxPages <= 0 {
+			s.MaxPages = 10000
+		}
+	}
+
+	if s.Auth != nil {
+		if err := s.Auth.resolve(); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+
+	s.hosts = []string{originURL.Host}
+	for _, u := range append([]string{s.ZendeskURL}, s.Seeds...) {
+		if host := hostOf(u); !slices.Contains(s.hosts, host) {
+			s.hosts = append(s.hosts, host)
+		}
+	}
+	return nil
+}
+
+// Config is the crawl configuration of the site: the shared settings with the site's own profile
+// and locales
+func (s *Site) Config(shared Config) Config {
+	config := shared
+	config.Site = s
+	config.Profile = s.profile
+	config.Locales = s.Locales
+	return config
+}
+
+// zendeskCredentials returns the site's Zendesk API email and token, empty when not configured
+func (s *Site) zendeskCredentials() (string, string) {
+	if s == nil {
+		return "", ""
+	}
+	return secret(s.ZendeskEmailEnv), secret(s.ZendeskTokenEnv)
+}
+
+// resolve checks the auth type and fills in the default credential names
+func (a *SiteAuth) resolve() error {
+	a.Type = strings.ToLower(a.Type)
+	switch a.Type {
+	case "basic", "form":
+		if a.UsernameEnv == "" {
+			a.UsernameEnv = "CRAWL_USERNAME"
+		}
+		if a.PasswordEnv == "" {
+			a.PasswordEnv = "CRAWL_PASSWORD"
+		}
+		if a.Type == "form" && a.LoginURL == "" {
+			return fmt.Errorf("login_url is required for form login")
+		}
+	case "bearer":
+		if a.TokenEnv == "" {
+			a.TokenEnv = "CRAWL_TOKEN"
+		}
+	case "cookie":
+		if a.CookieEnv == "" {
+			a.CookieEnv = "CRAWL_COOKIE"
+		}
+	default:
+		return fmt.Errorf("unknown type %q (use basic, bearer, cookie or form)", a.Type)
+	}
+	return nil
+}
+
+// signIn opens the site's session: it reads the credentials and, for form login, logs in. Sites
+// without auth have no session and their requests go out anonymous.
+func (s *Site) signIn(config Config) error {
+	if s.Auth == nil {
+		return nil
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return err
+	}
+	session := &siteSession{auth: s.Auth, jar: jar, client: &http.Client{Timeout: config.RequestTimeout, Jar: jar}, userAgent: config.Robots.UserAgent()}
+
+	missing := func(name string) error {
+		return fmt.Errorf("%s auth needs %s, set it in the environment or the secrets file", s.Auth.Type, name)
+	}
+	switch s.Auth.Type {
+	case "basic", "form":
+		session.username, session.password = secret(s.Auth.UsernameEnv), secret(s.Auth.PasswordEnv)
+		if session.username == "" {
+			return missing(s.Auth.UsernameEnv)
+		}
+		if session.password == "" {
+			return missing(s.Auth.PasswordEnv)
+		}
+		if s.Auth.Type == "basic" {
+			session.header = "Basic " + base64.StdEncoding.EncodeToString([]byte(session.username+":"+session.password))
+		}
+	case "bearer":
+		token := secret(s.Auth.TokenEnv)
+		if token == "" {
+			return missing(s.Auth.TokenEnv)
+		}
+		session.header = "Bearer " + token
+	case "cookie":
+		header := secret(s.Auth.CookieEnv)
+		if header == "" {
+			return missing(s.Auth.CookieEnv)
+		}
+		cookies, err := http.ParseCookie(header)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.Auth.CookieEnv, err)
+		}
+		// Host-only cookies, as the browser they were copied from held them
+		for _, host := range s.hosts {
+			jar.SetCookies(&url.URL{Scheme: "https", Host: host}, cookies)
+		}
+	}
+
+	if s.Auth.Type == "form" {
+		if err := session.login(); err != nil {
+			return err
+		}
+	}
+	s.session = session
+	return nil
+}
+
+// login submits the site's login form: it loads the login page for the form and the hidden
+// fields it carries (CSRF tokens), fills in the credentials and posts it, keeping the session
+// cookies in the jar
+func (ss *siteSession) login() error {
+	page, err := ss.get(ss.auth.LoginURL)
+	if err != nil {
+		return fmt.Errorf("login page: %w", err)
+	}
+	form := page.doc.Find("form").FilterFunction(func(_ int, form *goquery.Selection) bool {
+		return form.Find("input[type=password]").Length() > 0
+	}).First()
+	if form.Length() == 0 {
+		return fmt.Errorf("no login form with a password field at %s", ss.auth.LoginURL)
+	}
+
+	values := url.Values{}
+	usernameField, passwordField := ss.auth.UsernameField, ss.auth.PasswordField
+	form.Find("input[name]").Each(func(_ int, input *goquery.Selection) {
+		name := input.AttrOr("name", "")
+		switch strings.ToLower(input.AttrOr("type", "text")) {
+		case "hidden":
+			values.Set(name, input.AttrOr("value", ""))
+		case "checkbox", "radio":
+			if _, checked := input.Attr("checked"); checked {
+				values.Set(name, input.AttrOr("value", "on"))
+			}
+		case "password":
+			if passwordField == "" {
+				passwordField = name
+			}
+		case "email", "text":
+			if usernameField == "" {
+				usernameField = name
+			}
+		}
+	})
+	if usernameField == "" || passwordField == "" {
+		return fmt.Errorf("could not tell the username and password fields of the login form at %s, set username_field and password_field", ss.auth.LoginURL)
+	}
+	values.Set(usernameField, ss.username)
+	values.Set(passwordField, ss.password)
+
+	action := page.url
+	if href := strings.TrimSpace(form.AttrOr("action", "")); href != "" {
+		if action, err = page.url.Parse(href); err != nil {
+			return fmt.Errorf("login form action %q: %w", href, err)
+		}
+	}
+	req, err := http.NewRequest("POST", action.String(), strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Referer", page.url.String())
+	result, err := ss.do(req)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+
+	// Rejected credentials bring the login form back
+	if result.doc.Find("input[type=password]").Length() > 0 {
+		return fmt.Errorf("login at %s failed: the login form came back, check the credentials", ss.auth.LoginURL)
+	}
+	ss.logins++
+	return nil
+}
+
+// loginPage is a page read while logging in, with the URL it ended up at after redirects
+type loginPage struct {
+	url *url.URL
+	doc *goquery.Document
+}
+
+func (ss *siteSession) get(pageURL string) (*loginPage, error) {
+	req, err := http.NewRequest("GET", pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return ss.do(req)
+}
+
+func (ss *siteSession) do(req *http.Request) (*loginPage, error) {
+	req.Header.Set("User-Agent", ss.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	resp, err := ss.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, &polite.HTTPStatusError{URL: req.URL.String(), StatusCode: resp.StatusCode, Message: "login request failed"}
+	}
+	doc, err := goquery.NewDocumentFromReader(io.LimitReader(resp.Body, maxSitemapBytes))
+	if err != nil {
+		return nil, err
+	}
+	return &loginPage{url: resp.Request.URL, doc: doc}, nil
+}
+
+// sessionLogins is the number of logins of the site's session, to hand back to renewSession
+func (s *Site) sessionLogins() int {
+	if s == nil || s.session == nil {
+		return 0
+	}
+	s.session.mu.Lock()
+	defer s.session.mu.Unlock()
+	return s.session.logins
+}
+
+// renewSession logs in again after a page hit the login wall, which for a form login usually
+// means the session expired. seen is sessionLogins from before the page was requested: when
+// another fetch already logged in since, the caller just retries. It reports whether the page
+// is worth requesting again.
+func (s *Site) renewSession(seen int) bool {
+	if s == nil || s.session == nil || s.Auth.Type != "form" {
+		return false
+	}
+	session := s.session
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.logins != seen {
+		return true
+	}
+	if session.logins > maxSessionRenewals {
+		return false
+	}
+	if err := session.login(); err != nil {
+		fmt.Printf("🔒 Could not log in to %s again: %v\n", s.Name, err)
+		session.logins = maxSessionRenewals + 1
+		return false
+	}
+	fmt.Printf("🔑 Hit a login wall on %s, logged in again\n", s.Name)
+	return true
+}
+
+// signedIn reports whether the site's requests carry credentials
+func (s *Site) signedIn() bool {
+	return s != nil && s.session != nil
+}
+
+// authorize adds the site's Authorization header to a request for one of the site's hosts;
+// other hosts, such as CDNs serving attachments, never see the credentials
+func (s *Site) authorize(header http.Header, requestURL string) {
+	if s == nil || s.session == nil || s.session.header == "" || !slices.Contains(s.hosts, hostOf(requestURL)) {
+		return
+	}
+	header.Set("Authorization", s.session.header)
+}
+
+// cookieJar is the jar holding the site's session cookies, nil when the site has no session
+func (s *Site) cookieJar() http.CookieJar {
+	if s == nil || s.session == nil {
+		return nil
+	}
+	return s.session.jar
+}
+
+// crawlSecrets are the values of the secrets file, see secret
+var crawlSecrets map[string]string
+
+// loadSecrets reads a YAML or JSON file of names and values, such as ACME_PASSWORD: "…", for the
+// credentials that shouldn't live in the environment
+func loadSecrets(path string) (map[string]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		fmt.Printf("⚠ Secrets file %s can be read by other users (mode %o), consider chmod 600\n", path, info.Mode().Perm())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	secrets := make(map[string]string)
+	if err := yaml.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return secrets, nil
+}
+
+// secret returns the credential called name: the environment variable, else the secrets file
+// entry, else ""
+func secret(name string) string {
+	if name == "" {
+		return ""
+	}
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return crawlSecrets[name]
+}
+
+// documentID makes an article ID unique across locales and sites, see documentID
+func (s *Site) documentID(id, locale string) string {
+	id = documentID(id, locale)
+	if s == nil || id == "" {
+		return id
+	}
+	return *s.IDPrefix + id
+}
+
+// tag records which site an article belongs to
+func (s *Site) tag(article *Article) {
+	if s == nil {
+		return
+	}
+	article.Source = s.Name
+	article.Site = hostOf(article.URL)
+}
+
+// siteForURL returns the crawled site serving a URL's host, nil for hosts no site serves. The
+// Site methods treat nil as no site: no ID prefix, no credentials.
+func siteForURL(pageURL string) *Site {
+	host := hostOf(pageURL)
+	for _, site := range crawlSites {
+		if slices.Contains(site.hosts, host) {
+			return site
+		}
+	}
+	return nil
+}
+
+func (m *MultiSiteSource) Name() string {
+	var names []string
+	for i, site := range m.sites {
+		names = append(names, site.Name+" ("+m.sources[i].Name()+")")
+	}
+	return strings.Join(names, ", ")
+}
+
+// Discover lists every site in turn. A site that cannot be listed is reported as a discovery
+// failure instead of aborting the others, which also keeps reconciliation from deleting its
+// articles.
+func (m *MultiSiteSource) Discover() ([]URL, []error, error) {
+	var all []URL
+	var failures []error
+	for i, source := range m.sources {
+		site := m.sites[i]
+		fmt.Printf("📋 %s: discovering articles via the %s source...\n", site.Name, source.Name())
+		urls, siteFailures, err := source.Discover()
+		if err != nil {
+			fmt.Printf("⚠ %s: %v\n", site.Name, err)
+			failures = append(failures, fmt.Errorf("%s: %w", site.Name, err))
+			continue
+		}
+		for _, failure := range siteFailures {
+			failures = append(failures, fmt.Errorf("%s: %w", site.Name, failure))
+		}
+
+		listed := 0
+		m.mu.Lock()
+		for _, u := range urls {
+			// A page listed by two sites is crawled once, as part of the first
+			if _, ok := m.owner[u.Loc]; ok {
+				continue
+			}
+			m.owner[u.Loc] = source
+			all = append(all, u)
+			listed++
+		}
+		m.mu.Unlock()
+		fmt.Printf("📄 %s: %d articles in %s\n", site.Name, listed, strings.Join(site.Locales, ", "))
+	}
+
+	if len(all) == 0 && len(failures) > 0 {
+		return nil, nil, fmt.Errorf("no site could be listed: %w", errors.Join(failures...))
+	}
+	return all, failures, nil
+}
+
+// Fetch hands the URL to the source of the site that listed it, or else serves its host
+func (m *MultiSiteSource) Fetch(articleURL string, cached CacheValidators) (*Article, CacheValidators, error) {
+	m.mu.Lock()
+	source, ok := m.owner[articleURL]
+	m.mu.Unlock()
+	if !ok {
+		index := slices.Index(m.sites, siteForURL(articleURL))
+		if index < 0 {
+			return nil, cached, fmt.Errorf("no crawled site serves %s", articleURL)
+		}
+		source = m.sources[index]
+	}
+	return source.Fetch(articleURL, cached)
+}
+
+func NewHTMLSource(sitemapURL string, config Config) *HTMLSource {
+	return &HTMLSource{sitemapURL: sitemapURL, config: config, lastMod: make(map[string]string), discovered: make(map[string]bool)}
+}
+
+func (h *HTMLSource) Name() string { return "html" }
+
+// Discover reads the sitemap (following indexes) and keeps the article pages of the crawled locales
+func (h *HTMLSource) Discover() ([]URL, []error, error) {
+	urls, failures, err := fetchSitemapURLs(h.sitemapURL, h.config.Robots, h.config.Limiter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	articles := filterArticles(urls, h.config.Profile, h.config.Locales)
+	h.remember(articles)
+	return articles, failures, nil
+}
+
+// remember keeps the discovered articles' lastmod and IDs for Fetch
+func (h *HTMLSource) remember(articles []URL) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, u := range articles {
+		if u.LastMod != "" {
+			h.lastMod[u.Loc] = u.LastMod
+		}
+		h.discovered[articleIDFromURL(u.Loc)] = true
+	}
+}
+
+func NewLinkCrawlSource(htmlSource *HTMLSource, site *Site) *LinkCrawlSource {
+	return &LinkCrawlSource{
+		HTMLSource: htmlSource,
+		seeds:      site.Seeds,
+		scope:      site.Scope,
+		maxDepth:   site.MaxDepth,
+		maxPages:   site.MaxPages,
+		client:     &http.Client{Timeout: htmlSource.config.RequestTimeout, Jar: site.cookieJar()},
+		kept:       make(map[string]*keptPage),
+	}
+}
+
+func (l *LinkCrawlSource) Name() string { return "crawl" }
+
+// Discover walks the site breadth-first from the seeds, following in-scope links up to maxDepth
+// clicks away. Pagination (rel=next, or ?page=N while pages keep turning up new links) is
+// followed at the same depth until it runs out. Pages are known by their canonical URL, so a
+// page reached through several URLs is visited and listed once.
+func (l *LinkCrawlSource) Discover() ([]URL, []error, error) {
+	visited := make(map[string]bool)
+	var queue []crawlTarget
+	for _, seed := range l.seeds {
+		if !visited[seed] {
+			visited[seed] = true
+			queue = append(queue, crawlTarget{url: seed, seed: true})
+		}
+	}
+
+	var urls []URL
+	var failures []error
+	truncated := false
+	for len(queue) > 0 {
+		batch := queue
+		queue = nil
+		pages := l.fetchPages(batch)
+
+		for i, target := range batch {
+			page := pages[i]
+			if page.err != nil {
+				// A missing page is a broken link or the end of the pagination; anything else
+				// leaves the listing incomplete
+				var statusErr *polite.HTTPStatusError
+				var blocked *polite.RobotsBlockedError
+				if errors.As(page.err, &blocked) || (errors.As(page.err, &statusErr) && statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 && !polite.IsThrottled(page.err) && !target.seed) {
+					continue
+				}
+				// Without credentials the gated part of the site is simply out of reach; with
+				// them, a login wall the session couldn't get past hides pages we should see
+				var authErr *AuthRequiredError
+				if errors.As(page.err, &authErr) && !l.config.Site.signedIn() && !target.seed {
+					continue
+				}
+				fmt.Printf("⚠ Could not crawl %s: %v\n", target.url, page.err)
+				failures = append(failures, page.err)
+				continue
+			}
+
+			pageURL := target.url
+			if page.canonical != "" && page.canonical != pageURL {
+				if visited[page.canonical] {
+					continue
+				}
+				visited[page.canonical] = true
+				pageURL = page.canonical
+			}
+			if l.config.Profile.Matches(pageURL) {
+				urls = append(urls, URL{Loc: pageURL, LastMod: page.lastModified})
+				l.keep(pageURL, page.page)
+			}
+
+			enqueue := func(link string, depth int) bool {
+				if visited[link] || !l.inScope(link) {
+					return false
+				}
+				if len(visited) >= l.maxPages {
+					truncated = true
+					return false
+				}
+				visited[link] = true
+				queue = append(queue, crawlTarget{url: link, depth: depth})
+				return true
+			}
+
+			found := 0
+			if target.depth < l.maxDepth {
+				for _, link := range page.links {
+					if enqueue(link, target.depth+1) {
+						found++
+					}
+				}
+			}
+			if page.next != "" {
+				enqueue(page.next, target.depth)
+			} else if next := nextPageURL(pageURL); next != "" && found > 0 {
+				enqueue(next, target.depth)
+			}
+		}
+	}
+
+	if truncated {
+		// Articles beyond the cap would look deleted to reconciliation
+		err := fmt.Errorf("link crawl stopped after %d pages (max_pages), the listing is incomplete", l.maxPages)
+		fmt.Printf("⚠ %v\n", err)
+		failures = append(failures, err)
+	}
+	if len(urls) == 0 && len(failures) > 0 {
+		return nil, nil, fmt.Errorf("no article found from the seeds: %w", errors.Join(failures...))
+	}
+
+	articles := filterArticles(urls, l.config.Profile, l.config.Locales)
+	fmt.Printf("🕷  Link crawl visited %d pages, %d articles\n", len(visited), len(articles))
+	l.remember(articles)
+	l.dropKeptExcept(articles)
+	return articles, failures, nil
+}
+
+// keep holds on to an article page for Fetch, as long as the pages kept stay under maxKeptBytes
+func (l *LinkCrawlSource) keep(articleURL string, page *keptPage) {
+	if page == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.keptBytes+int64(len(page.body)) > maxKeptBytes {
+		return
+	}
+	l.kept[articleURL] = page
+	l.keptBytes += int64(len(page.body))
+}
+
+// dropKeptExcept forgets the kept pages that filterArticles left out, Fetch never asks for them
+func (l *LinkCrawlSource) dropKeptExcept(articles []URL) {
+	listed := make(map[string]bool, len(articles))
+	for _, u := range articles {
+		listed[u.Loc] = true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for articleURL, page := range l.kept {
+		if !listed[articleURL] {
+			delete(l.kept, articleURL)
+			l.keptBytes -= int64(len(page.body))
+		}
+	}
+}
+
+// RoundTrip answers the scraper's request for the kept page without going to the server
+func (p *keptPage) RoundTrip(req *http.Request) (*http.Response, error) {
+	status, body := http.StatusOK, p.body
+	etag, lastModified := p.header.Get("ETag"), p.header.Get("Last-Modified")
+	if (etag != "" && req.Header.Get("If-None-Match") == etag) || (etag == "" && lastModified != "" && req.Header.Get("If-Modified-Since") == lastModified) {
+		status, body = http.StatusNotModified, nil
+	}
+
+	header := p.header.Clone()
+	header.Del("Content-Encoding")
+	header.Del("Content-Length")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// Fetch scrapes an article from the page Discover kept, and downloads it like HTMLSource when
+// there is none, as on a retry
+func (l *LinkCrawlSource) Fetch(articleURL string, cached CacheValidators) (*Article, CacheValidators, error) {
+	l.mu.Lock()
+	page, ok := l.kept[articleURL]
+	if ok {
+		delete(l.kept, articleURL)
+		l.keptBytes -= int64(len(page.body))
+	}
+	l.mu.Unlock()
+	if !ok {
+		return l.HTMLSource.Fetch(articleURL, cached)
+	}
+
+	article, fresh, err := scrapeFullArticle(articleURL, l.config, cached, page)
+	return l.complete(articleURL, article), fresh, err
+}
+
+// fetchPages crawls a batch of pages concurrently, returning them in the batch's order
+func (l *LinkCrawlSource) fetchPages(batch []crawlTarget) []crawledPage {
+	pages := make([]crawledPage, len(batch))
+	semaphore := make(chan struct{}, l.config.FetchConcurrency)
+	var wg sync.WaitGroup
+	for i, target := range batch {
+		wg.Add(1)
+		go func(i int, pageURL string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			logins := l.config.Site.sessionLogins()
+			pages[i] = l.fetchPage(pageURL)
+			var authErr *AuthRequiredError
+			if errors.As(pages[i].err, &authErr) && l.config.Site.renewSession(logins) {
+				pages[i] = l.fetchPage(pageURL)
+			}
+		}(i, target.url)
+	}
+	wg.Wait()
+	return pages
+}
+
+// fetchPage downloads one page and reads its links, canonical URL and pagination
+func (l *LinkCrawlSource) fetchPage(pageURL string) crawledPage {
+	if allowed, rule := l.config.Robots.Allowed(pageURL); !allowed {
+		fmt.Printf("🤖 Not following %s: blocked by robots.txt rule %s\n", pageURL, rule)
+		return crawledPage{err: &polite.RobotsBlockedError{URL: pageURL, Rule: rule}}
+	}
+	l.config.Limiter.Wait(pageURL)
+
+	req, err := http.NewRequest("GET", pageURL, nil)
+	if err != nil {
+		return crawledPage{err: err}
+	}
+	req.Header.Set("User-Agent", l.config.Robots.UserAgent())
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	l.config.Site.authorize(req.Header, pageURL)
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		l.config.Limiter.Report(pageURL, err)
+		return crawledPage{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		l.config.Limiter.Report(pageURL, nil)
+		return crawledPage{err: &AuthRequiredError{URL: pageURL, Reason: "HTTP 401"}}
+	}
+	if final := resp.Request.URL.String(); final != pageURL && l.config.Profile.IsLoginURL(final) {
+		l.config.Limiter.Report(pageURL, nil)
+		return crawledPage{err: &AuthRequiredError{URL: pageURL, Reason: "redirected to " + final}}
+	}
+	if resp.StatusCode != http.StatusOK {
+		statusErr := &polite.HTTPStatusError{URL: pageURL, StatusCode: resp.StatusCode, Message: "page request failed", RetryAfter: polite.ParseRetryAfter(resp.Header.Get("Retry-After"))}
+		l.config.Limiter.Report(pageURL, statusErr)
+		return crawledPage{err: statusErr}
+	}
+	l.config.Limiter.Report(pageURL, nil)
+
+	page := crawledPage{lastModified: resp.Header.Get("Last-Modified")}
+	if !strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		return page
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSitemapBytes))
+	if err != nil {
+		return crawledPage{err: fmt.Errorf("failed to read %s: %v", pageURL, err)}
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return crawledPage{err: fmt.Errorf("failed to parse %s: %v", pageURL, err)}
+	}
+	// Only a page that wasn't redirected can stand in for a request to its URL
+	if resp.Request.URL.String() == pageURL {
+		page.page = &keptPage{header: resp.Header.Clone(), body: body}
+	}
+
+	// Redirects change the URL relative links resolve against
+	base := resp.Request.URL
+	if href, ok := doc.Find(`link[rel="canonical"]`).Attr("href"); ok {
+		if canonical := canonicalURL(base, href); canonical != "" && l.inScope(canonical) {
+			page.canonical = canonical
+		}
+	}
+	if href, ok := doc.Find(`link[rel="next"], a[rel="next"]`).Attr("href"); ok {
+		page.next = canonicalURL(base, href)
+	}
+	seen := make(map[string]bool)
+	doc.Find("a[href]").Each(func(_ int, a *goquery.Selection) {
+		link := canonicalURL(base, a.AttrOr("href", ""))
+		if link == "" || seen[link] || attachmentExtensions[strings.ToLower(path.Ext(link))] {
+			return
+		}
+		seen[link] = true
+		page.links = append(page.links, link)
+	})
+	return page
+}
+
+// inScope reports whether a link starts with one of the site's scope prefixes
+func (l *LinkCrawlSource) inScope(link string) bool {
+	for _, prefix := range l.scope {
+		if strings.HasPrefix(link, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// trackingParams are query parameters that identify a campaign or a click, never content;
+// utm_* parameters are dropped too
+var trackingParams = map[string]bool{
+	"gclid": true, "fbclid": true, "msclkid": true, "yclid": true, "igshid": true,
+	"mc_cid": true, "mc_eid": true, "_ga": true, "_gl": true,
+}
+
+// canonicalURL resolves ref against base and normalizes it, so one page has one URL: lowercase
+// scheme and host, no default port, fragment or tracking parameters, and sorted query
+// parameters. The path is kept as it is, trailing slash included, since a server may serve
+// different pages for both. Anything but an http(s) URL gives "".
+func canonicalURL(base *url.URL, ref string) string {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+
+	u.Host = strings.ToLower(u.Host)
+	u.Host = strings.TrimSuffix(u.Host, map[string]string{"http": ":80", "https": ":443"}[u.Scheme])
+	u.User = nil
+	u.Fragment, u.RawFragment = "", ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	query := u.Query()
+	for key := range query {
+		if lower := strings.ToLower(key); strings.HasPrefix(lower, "utm_") || trackingParams[lower] {
+			query.Del(key)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// nextPageURL returns the URL of the page after a ?page=N listing page, "" for other pages
+func nextPageURL(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	query := u.Query()
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		return ""
+	}
+	query.Set("page", strconv.Itoa(page+1))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// crawledTranslations keeps the hreflang translations the sitemap lists in a crawled locale, one
+// per locale. Search only falls back to English for articles with no translation in the
+// requested language, so a translation that never gets indexed must not be listed.
+func (h *HTMLSource) crawledTranslations(translations []Translation) []Translation {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var kept []Translation
+	for _, translation := range translations {
+		if h.discovered[translation.ArticleID] && !seen[translation.Locale] {
+			seen[translation.Locale] = true
+			kept = append(kept, translation)
+		}
+	}
+	return kept
+}
+
+func (h *HTMLSource) Fetch(articleURL string, cached CacheValidators) (*Article, CacheValidators, error) {
+	if allowed, rule := h.config.Robots.Allowed(articleURL); !allowed {
+		fmt.Printf("🤖 Skipping %s: blocked by robots.txt rule %s\n", articleURL, rule)
+		return nil, cached, &polite.RobotsBlockedError{URL: articleURL, Rule: rule}
+	}
+
+	// Shared per-host token bucket, capped by the host's Crawl-delay
+	h.config.Limiter.Wait(articleURL)
+	article, fresh, err := scrapeFullArticle(articleURL, h.config, cached, nil)
+	h.config.Limiter.Report(articleURL, err)
+	return h.complete(articleURL, article), fresh, err
+}
+
+// complete fills in what a scraped article takes from discovery: the sitemap's lastmod for a
+// missing modified date, and which of its translations are crawled
+func (h *HTMLSource) complete(articleURL string, article *Article) *Article {
+	// A page without a modified date falls back to the sitemap's lastmod, which is at least
+	// the site's own claim rather than the time we happened to crawl it
+	if article != nil && article.UpdatedAt == "" {
+		h.mu.Lock()
+		lastMod := h.lastMod[articleURL]
+		h.mu.Unlock()
+		if date := normalizeDate(lastMod); date != "" {
+			article.UpdatedAt = date
+			article.UpdatedAtSource = dateSourceSitemap
+		}
+	}
+	if article != nil {
+		article.Translations = h.crawledTranslations(article.Translations)
+	}
+	return article
+}
+
+func NewZendeskAPISource(baseURL string, locales []string, config Config) *ZendeskAPISource {
+	email, apiToken := config.Site.zendeskCredentials()
+	return &ZendeskAPISource{
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		locales:      locales,
+		email:        email,
+		apiToken:     apiToken,
+		config:       config,
+		client:       &http.Client{Timeout: config.RequestTimeout},
+		listed:       make(map[string]*zendeskArticle),
+		translations: make(map[int64][]Translation),
+		sections:     make(map[localizedID]ZendeskSection),
+		categories:   make(map[localizedID]ZendeskCategory),
+	}
+}
+
+func (z *ZendeskAPISource) Name() string { return "zendesk" }
+
+// Probe checks the Help Center API answers with a single-article page
+func (z *ZendeskAPISource) Probe() error {
+	var page zendeskPage
+	_, err := z.getJSON(z.endpoint(z.locales[0], "articles.json?per_page=1"), CacheValidators{}, &page)
+	return err
+}
+
+// Discover pages through every article, section and category of each locale. The article
+// listing already carries the full body, so Fetch rarely needs another request.
+func (z *ZendeskAPISource) Discover() ([]URL, []error, error) {
+	z.loadSectionsAndCategories()
+
+	var urls []URL
+	for _, locale := range z.locales {
+		err := z.eachPage(z.endpoint(locale, "articles.json?per_page=100&sort_by=updated_at&sort_order=desc"), func(page *zendeskPage) {
+			for i := range page.Articles {
+				if page.Articles[i].Locale == "" {
+					page.Articles[i].Locale = locale
+				}
+			}
+			urls = append(urls, z.list(page.Articles)...)
+		})
+		// Without the complete article listing every unlisted article would look deleted
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list Zendesk articles in %s: %v", locale, err)
+		}
+	}
+
+	fmt.Printf("📚 Zendesk API: %d articles in %d sections and %d categories\n", len(urls), len(z.sections), len(z.categories))
+	return urls, nil, nil
+}
+
+// list remembers published articles for Fetch and returns their URLs
+func (z *ZendeskAPISource) list(articles []zendeskArticle) []URL {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	var urls []URL
+	for i := range articles {
+		article := &articles[i]
+		if article.Draft || article.HTMLURL == "" {
+			continue
+		}
+		article.Locale = strings.ToLower(article.Locale)
+		z.listed[article.HTMLURL] = article
+		z.addTranslation(article)
+		urls = append(urls, URL{Loc: article.HTMLURL, LastMod: article.UpdatedAt})
+	}
+	return urls
+}
+
+// addTranslation records a listed article under its Zendesk ID, which its translations share.
+// Callers hold z.mu.
+func (z *ZendeskAPISource) addTranslation(article *zendeskArticle) {
+	for _, known := range z.translations[article.ID] {
+		if known.Locale == article.Locale {
+			return
+		}
+	}
+	z.translations[article.ID] = append(z.translations[article.ID], Translation{
+		Locale:    article.Locale,
+		URL:       article.HTMLURL,
+		ArticleID: z.config.Site.documentID(strconv.FormatInt(article.ID, 10), article.Locale),
+	})
+}
+
+// loadSectionsAndCategories fills the lookups used to label articles, in every locale. They only
+// enrich articles, so a failure here is logged and doesn't make the listing incomplete.
+func (z *ZendeskAPISource) loadSectionsAndCategories() {
+	for _, locale := range z.locales {
+		if err := z.eachPage(z.endpoint(locale, "categories.json?per_page=100"), func(page *zendeskPage) {
+			for _, category := range page.Categories {
+				z.categories[localizedID{locale, category.ID}] = category
+			}
+		}); err != nil {
+			fmt.Printf("⚠ Could not list Zendesk categories in %s: %v\n", locale, err)
+		}
+		if err := z.eachPage(z.endpoint(locale, "sections.json?per_page=100"), func(page *zendeskPage) {
+			for _, section := range page.Sections {
+				z.sections[localizedID{locale, section.ID}] = section
+			}
+		}); err != nil {
+			fmt.Printf("⚠ Could not list Zendesk sections in %s: %v\n", locale, err)
+		}
+	}
+}
+
+// Fetch returns the article from the Discover listing, or asks the API for it by ID
+func (z *ZendeskAPISource) Fetch(articleURL string, cached CacheValidators) (*Article, CacheValidators, error) {
+	z.mu.Lock()
+	listed, ok := z.listed[articleURL]
+	z.mu.Unlock()
+	if ok {
+		return z.toArticle(listed), CacheValidators{}, nil
+	}
+
+	id := zendeskIDFromURL(articleURL)
+	if id == "" {
+		return nil, cached, fmt.Errorf("no article ID in %s", articleURL)
+	}
+	locale := localeFromURL(articleURL)
+	if locale == "" {
+		locale = z.locales[0]
+	}
+
+	var response struct {
+		Article zendeskArticle `json:"article"`
+	}
+	fresh, err := z.getJSON(z.endpoint(locale, "articles/"+id+".json"), cached, &response)
+	if err != nil {
+		return nil, cached, err
+	}
+	if response.Article.Locale == "" {
+		response.Article.Locale = locale
+	}
+	response.Article.Locale = strings.ToLower(response.Article.Locale)
+	return z.toArticle(&response.Article), fresh, nil
+}
+
+func (z *ZendeskAPISource) toArticle(a *zendeskArticle) *Article {
+	article := &Article{
+		ID:        z.config.Site.documentID(strconv.FormatInt(a.ID, 10), a.Locale),
+		Title:     a.Title,
+		URL:       a.HTMLURL,
+		CreatedAt: normalizeDate(a.CreatedAt),
+		UpdatedAt: normalizeDate(a.UpdatedAt),
+		SectionID: a.SectionID,
+		Locale:    a.Locale,
+	}
+	setBody(article, a.Body)
+	z.config.Site.tag(article)
+	if article.CreatedAt != "" {
+		article.CreatedAtSource = dateSourceAPI
+	}
+	if article.UpdatedAt != "" {
+		article.UpdatedAtSource = dateSourceAPI
+	}
+	article.DatesUnreliable = article.CreatedAt == "" && article.UpdatedAt == ""
+
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	for _, translation := range z.translations[a.ID] {
+		if translation.Locale != a.Locale {
+			article.Translations = append(article.Translations, translation)
+		}
+	}
+
+	section, ok := z.sections[localizedID{a.Locale, a.SectionID}]
+	if !ok {
+		return article
+	}
+	article.SectionName = section.Name
+
+	// Walk up nested sections to the top-level one, which belongs to the category
+	trail := []string{section.Name}
+	for depth := 0; section.ParentSectionID != 0 && depth < 10; depth++ {
+		parent, ok := z.sections[localizedID{a.Locale, section.ParentSectionID}]
+		if !ok {
+			break
+		}
+		section = parent
+		trail = append([]string{section.Name}, trail...)
+	}
+
+	article.CategoryID = section.CategoryID
+	if category, ok := z.categories[localizedID{a.Locale, section.CategoryID}]; ok {
+		article.CategoryName = category.Name
+		trail = append([]string{category.Name}, trail...)
+	}
+	article.Breadcrumbs = trail
+	return article
+}
+
+func NewZendeskIncrementalSource(api *ZendeskAPISource, cursorPath string, fullExport bool) *ZendeskIncrementalSource {
+	return &ZendeskIncrementalSource{ZendeskAPISource: api, cursorPath: cursorPath, fullExport: fullExport}
+}
+
+func (z *ZendeskIncrementalSource) Name() string { return "zendesk-incremental" }
+
+// Discover exports every article changed since the saved cursor; the first run (or FULL_CRAWL)
+// starts from the beginning of time and so lists everything
+func (z *ZendeskIncrementalSource) Discover() ([]URL, []error, error) {
+	cursor, err := z.loadCursor()
+	if err != nil {
+		return nil, nil, err
+	}
+	if z.fullExport {
+		cursor.StartTime = 0
+	}
+	if cursor.StartTime == 0 {
+		fmt.Println("📤 No Zendesk export cursor, exporting every article")
+	} else {
+		fmt.Printf("📤 Exporting Zendesk articles changed since %s\n", time.Unix(cursor.StartTime, 0).UTC().Format(time.RFC3339))
+	}
+
+	z.loadSectionsAndCategories()
+
+	var urls []URL
+	z.endTime = cursor.StartTime
+	z.removed = nil
+	pageURL := fmt.Sprintf("%s/api/v2/help_center/incremental/articles?start_time=%d", z.baseURL, cursor.StartTime)
+	err = z.eachPage(pageURL, func(page *zendeskPage) {
+		// The export covers every locale, keep ours. Unlike the listing it also has the
+		// articles that were unpublished, which have to come out of the index.
+		var articles []zendeskArticle
+		for _, article := range page.Articles {
+			if article.Locale == "" {
+				article.Locale = z.locales[0]
+			}
+			article.Locale = strings.ToLower(article.Locale)
+			if !slices.Contains(z.locales, article.Locale) {
+				continue
+			}
+			if (article.Draft || article.Archived) && article.HTMLURL != "" {
+				z.removed = append(z.removed, IndexedDocument{
+					ID:    z.config.Site.documentID(strconv.FormatInt(article.ID, 10), article.Locale),
+					Title: article.Title,
+					URL:   article.HTMLURL,
+				})
+				continue
+			}
+			articles = append(articles, article)
+		}
+		urls = append(urls, z.list(articles)...)
+
+		if page.EndTime > z.endTime {
+			z.endTime = page.EndTime
+		}
+		// The export keeps handing out next_page links; an empty page is the end of the stream
+		if len(page.Articles) == 0 {
+			page.NextPage = ""
+		}
+	})
+	// A partial export can't advance the cursor, everything is exported again next run
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to export Zendesk articles: %v", err)
+	}
+
+	fmt.Printf("📚 Zendesk export: %d articles changed and %d unpublished, cursor can advance to %s\n", len(urls), len(z.removed), time.Unix(z.endTime, 0).UTC().Format(time.RFC3339))
+	return urls, nil, nil
+}
+
+// Removed returns the articles the export reported as drafted or archived
+func (z *ZendeskIncrementalSource) Removed() []IndexedDocument {
+	return z.removed
+}
+
+// CommitCursor saves the export position reached by Discover, via a temp file and rename
+func (z *ZendeskIncrementalSource) CommitCursor() error {
+	data, err := json.MarshalIndent(ZendeskCursor{StartTime: z.endTime, SavedAt: time.Now().UTC()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal export cursor: %v", err)
+	}
+
+	tmpPath := z.cursorPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write export cursor: %v", err)
+	}
+	if err := os.Rename(tmpPath, z.cursorPath); err != nil {
+		return fmt.Errorf("failed to replace export cursor: %v", err)
+	}
+	return nil
+}
+
+func (z *ZendeskIncrementalSource) loadCursor() (ZendeskCursor, error) {
+	var cursor ZendeskCursor
+
+	data, err := os.ReadFile(z.cursorPath)
+	if os.IsNotExist(err) {
+		return cursor, nil
+	}
+	if err != nil {
+		return cursor, fmt.Errorf("failed to read export cursor %s: %v", z.cursorPath, err)
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, fmt.Errorf("failed to parse export cursor %s: %v", z.cursorPath, err)
+	}
+	return cursor, nil
+}
+
+func (z *ZendeskAPISource) endpoint(locale, path string) string {
+	return fmt.Sprintf("%s/api/v2/help_center/%s/%s", z.baseURL, locale, path)
+}
+
+// eachPage follows next_page links from firstURL, handing every page to visit
+func (z *ZendeskAPISource) eachPage(firstURL string, visit func(page *zendeskPage)) error {
+	const maxPages = 1000
+
+	pageURL := firstURL
+	for pages := 0; pageURL != ""; pages++ {
+		if pages == maxPages {
+			return fmt.Errorf("stopped after %d pages of %s", maxPages, firstURL)
+		}
+
+		var page zendeskPage
+		if _, err := z.getWithRetry(pageURL, &page); err != nil {
+			return err
+		}
+		visit(&page)
+		pageURL = page.NextPage
+	}
+	return nil
+}
+
+// getWithRetry retries throttled and transient listing requests; the limiter does the waiting
+func (z *ZendeskAPISource) getWithRetry(apiURL string, out interface{}) (CacheValidators, error) {
+	var err error
+	for attempt := 0; attempt <= z.config.MaxRetries; attempt++ {
+		var fresh CacheValidators
+		fresh, err = z.getJSON(apiURL, CacheValidators{}, out)
+		if err == nil {
+			return fresh, nil
+		}
+
+		var blocked *polite.RobotsBlockedError
+		var statusErr *polite.HTTPStatusError
+		if errors.As(err, &blocked) || (errors.As(err, &statusErr) && statusErr.StatusCode < 500 && !polite.IsThrottled(err)) {
+			return fresh, err
+		}
+		if !polite.IsThrottled(err) {
+			time.Sleep(time.Duration(attempt+1) * z.config.FetchDelay)
+		}
+	}
+	return CacheValidators{}, err
+}
+
+// getJSON makes one rate-limited, robots-checked, optionally conditional API request
+func (z *ZendeskAPISource) getJSON(apiURL string, cached CacheValidators, out interface{}) (CacheValidators, error) {
+	if allowed, rule := z.config.Robots.Allowed(apiURL); !allowed {
+		fmt.Printf("🤖 Skipping %s: blocked by robots.txt rule %s\n", apiURL, rule)
+		return cached, &polite.RobotsBlockedError{URL: apiURL, Rule: rule}
+	}
+
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return cached, err
+	}
+	req.Header.Set("User-Agent", z.config.Robots.UserAgent())
+	req.Header.Set("Accept", "application/json")
+	if z.email != "" && z.apiToken != "" {
+		req.SetBasicAuth(z.email+"/token", z.apiToken)
+	}
+	if cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+	if cached.LastModified != "" {
+		req.Header.Set("If-Modified-Since", cached.LastModified)
+	}
+
+	z.config.Limiter.Wait(apiURL)
+	resp, err := z.client.Do(req)
+	if err != nil {
+		z.config.Limiter.Report(apiURL, err)
+		return cached, fmt.Errorf("request to %s failed: %v", apiURL, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		err = errNotModified
+	case resp.StatusCode != http.StatusOK:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err = &polite.HTTPStatusError{
+			URL:        apiURL,
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(body)),
+			RetryAfter: polite.ParseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	z.config.Limiter.Report(apiURL, err)
+	if err != nil {
+		return cached, err
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return cached, fmt.Errorf("failed to decode %s: %v", apiURL, err)
+	}
+
+	return CacheValidators{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}, nil
+}
+
+// loadSiteProfile reads the site profile at path over the built-in Zendesk profile; an empty
+// path returns the built-in profile
+func loadSiteProfile(path string) (*SiteProfile, error) {
+	profile := &SiteProfile{}
+	if err := decodeSiteProfile(defaultSiteProfileYAML, profile); err != nil {
+		return nil, fmt.Errorf("built-in profile: %w", err)
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := decodeSiteProfile(data, profile); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	if err := profile.compile(); err != nil {
+		return nil, fmt.Errorf("profile %q: %w", profile.Name, err)
+	}
+	return profile, nil
+}
+
+// decodeSiteProfile decodes a YAML or JSON profile (YAML parses JSON too) into profile, replacing
+// only the keys it sets. Unknown keys are errors, so a misspelled rule doesn't silently fall back
+// to the Zendesk default.
+func decodeSiteProfile(data []byte, profile *SiteProfile) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(profile); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// compile checks every pattern and selector, so a broken profile fails at startup instead of
+// quietly extracting nothing
+func (p *SiteProfile) compile() error {
+	var err error
+	if p.include, err = compilePatterns(p.Include); err != nil {
+		return fmt.Errorf("include: %w", err)
+	}
+	if p.exclude, err = compilePatterns(p.Exclude); err != nil {
+		return fmt.Errorf("exclude: %w", err)
+	}
+	if len(p.include) == 0 {
+		return fmt.Errorf("include needs at least one pattern")
+	}
+	if p.loginURLs, err = compilePatterns(p.LoginURLs); err != nil {
+		return fmt.Errorf("login_urls: %w", err)
+	}
+
+	if p.idPattern, err = regexp.Compile(p.IDPattern); err != nil {
+		return fmt.Errorf("id_pattern: %w", err)
+	}
+	if p.idPattern.NumSubexp() < 1 {
+		return fmt.Errorf("id_pattern %q needs a capture group for the article ID", p.IDPattern)
+	}
+
+	selectors := []struct {
+		key       string
+		selectors []string
+		required  bool
+	}{
+		{"title", p.Title, true},
+		{"body", p.Body, true},
+		{"strip", p.Strip, false},
+		{"breadcrumbs", p.Breadcrumbs, false},
+		{"dates", p.Dates, false},
+		{"date_text", p.DateText, false},
+		{"login_form", p.LoginForm, false},
+	}
+	for _, s := range selectors {
+		if s.required && len(s.selectors) == 0 {
+			return fmt.Errorf("%s needs at least one selector", s.key)
+		}
+		for _, selector := range s.selectors {
+			if _, err := cascadia.Compile(selector); err != nil {
+				return fmt.Errorf("%s selector %q: %w", s.key, selector, err)
+			}
+		}
+	}
+	return nil
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// Matches reports whether a URL is an article page: an include pattern matches and no exclude
+// pattern does
+func (p *SiteProfile) Matches(pageURL string) bool {
+	included := false
+	for _, re := range p.include {
+		if re.MatchString(pageURL) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, re := range p.exclude {
+		if re.MatchString(pageURL) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsLoginURL reports whether a URL is one of the site's sign-in pages
+func (p *SiteProfile) IsLoginURL(pageURL string) bool {
+	for _, re := range p.loginURLs {
+		if re.MatchString(pageURL) {
+			return true
+		}
+	}
+	return false
+}
+
+// titleLine returns the first line of a title element's text that is neither metadata, such as
+// the "Published … • Last Updated …" line, nor page chrome like "How can we help?"
+func (p *SiteProfile) titleLine(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || slices.Contains(p.IgnoreTitles, line) {
+			continue
+		}
+		metadata := false
+		for _, skip := range p.TitleSkipLines {
+			if strings.Contains(line, skip) {
+				metadata = true
+				break
+			}
+		}
+		if !metadata {
+			return line
+		}
+	}
+	return ""
+}
+
+// validateProfile scrapes one page with the site profile and prints what it extracted, so a
+// profile can be checked before crawling a site with it. It returns false when discovery would
+// skip the URL or the page would not be indexed.
+func validateProfile(articleURL string, config Config) bool {
+	profile := config.Profile
+	fmt.Printf("🧪 Validating site profile %q against %s\n", profile.Name, articleURL)
+
+	valid := true
+	if !profile.Matches(articleURL) {
+		fmt.Println("⚠ The URL does not match the profile's include/exclude patterns, discovery would skip it")
+		valid = false
+	}
+	if articleIDFromURL(articleURL) == "" {
+		fmt.Printf("⚠ id_pattern %q finds no article ID in the URL\n", profile.IDPattern)
+		valid = false
+	}
+
+	article, _, err := scrapeFullArticle(articleURL, config, CacheValidators{}, nil)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return false
+	}
+
+	orNone := func(value string) string {
+		if value == "" {
+			return "(none)"
+		}
+		return value
+	}
+	withSource := func(date, source string) string {
+		if date == "" {
+			return "(none)"
+		}
+		return fmt.Sprintf("%s (%s)", date, source)
+	}
+	preview := collapseWhitespace(article.BodyText)
+	if len([]rune(preview)) > 300 {
+		preview = string([]rune(preview)[:300]) + "…"
+	}
+
+	fmt.Printf("  ID:          %s\n", orNone(article.ID))
+	fmt.Printf("  Locale:      %s\n", article.Locale)
+	fmt.Printf("  Title:       %s\n", article.Title)
+	fmt.Printf("  Created:     %s\n", withSource(article.CreatedAt, article.CreatedAtSource))
+	fmt.Printf("  Updated:     %s\n", withSource(article.UpdatedAt, article.UpdatedAtSource))
+	fmt.Printf("  Breadcrumbs: %s\n", orNone(strings.Join(article.Breadcrumbs, " › ")))
+	fmt.Printf("  Body:        %d bytes of HTML, %d words, %d passages\n", len(article.Body), len(strings.Fields(article.BodyText)), len(article.Passages))
+	fmt.Printf("  Assets:      %d images, %d attachments, %d internal and %d external links\n",
+		len(article.Images), len(article.Attachments), len(article.InternalLinks), len(article.ExternalLinks))
+	for _, translation := range article.Translations {
+		fmt.Printf("  Translation: %s %s\n", translation.Locale, translation.URL)
+	}
+	fmt.Printf("  Text:        %s\n", preview)
+
+	if article.CreatedAt == "" && article.UpdatedAt == "" {
+		fmt.Println("⚠ No dates found, check the dates and date_text selectors")
+	}
+	if len(article.Breadcrumbs) == 0 && len(profile.Breadcrumbs) > 0 {
+		fmt.Println("⚠ No breadcrumbs found, check the breadcrumbs selectors")
+	}
+
+	if valid {
+		fmt.Println("✅ Profile extracts an indexable article")
+	}
+	return valid
+}
+
+// filterArticles keeps the article pages of the given locales. Pages whose URL carries no
+// locale, as on sites that aren't Zendesk help centers, are kept.
+func filterArticles(urls []URL, profile *SiteProfile, locales []string) []URL {
+	var filtered []URL
+
+	wanted := make(map[string]bool, len(locales))
+	for _, locale := range locales {
+		wanted[locale] = true
+	}
+
+	for _, url := range urls {
+		if locale := localeFromURL(url.Loc); locale != "" && !wanted[locale] {
+			continue
+		}
+		if profile.Matches(url.Loc) {
+			filtered = append(filtered, url)
+		}
+	}
+
+	return filtered
+}
+
+// fetchArticlesConcurrently fetches every URL with bounded concurrency. Closing stop prevents
+// new fetches from starting; URLs that never started produce no result.
+func fetchArticlesConcurrently(source ArticleSource, articleURLs []string, validators map[string]CacheValidators, config Config, stop <-chan struct{}) <-chan FetchResult {
+	results := make(chan FetchResult, len(articleURLs))
+	semaphore := make(chan struct{}, config.FetchConcurrency)
+	var wg sync.WaitGroup
+
+	for _, url := range articleURLs {
+		wg.Add(1)
+		go func(articleURL string) {
+			defer wg.Done()
+
+			// Acquire semaphore for concurrency control
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			// Implement retry logic
+			var lastErr error
+			renewed := false // a login wall gets one retry with a renewed session
+			for attempt := 0; attempt <= config.MaxRetries; attempt++ {
+				select {
+				case <-stop:
+					if attempt == 0 {
+						return
+					}
+					// Report the failure so --resume retries it
+					results <- FetchResult{Error: fmt.Errorf("interrupted: %v", lastErr), URL: articleURL, Retries: attempt - 1}
+					return
+				default:
+				}
+
+				if attempt > 0 {
+					// Throttled requests already wait in the limiter (Retry-After and a lower rate),
+					// only back off here for other failures
+					if !polite.IsThrottled(lastErr) {
+						backoff := time.Duration(attempt) * config.FetchDelay
+						time.Sleep(backoff)
+					}
+					fmt.Printf("Retrying %s (attempt %d/%d)\n", articleURL, attempt, config.MaxRetries)
+				}
+
+				// Sources check robots.txt and take their turn in the per-host rate limiter
+				site := siteForURL(articleURL)
+				logins := site.sessionLogins()
+				article, fresh, err := source.Fetch(articleURL, validators[articleURL])
+				if err == nil {
+					config.Assets.Process(article)
+					results <- FetchResult{
+						Article:    article,
+						Error:      nil,
+						URL:        articleURL,
+						Retries:    attempt,
+						Validators: fresh,
+					}
+					return
+				}
+				if errors.Is(err, errNotModified) {
+					results <- FetchResult{
+						URL:         articleURL,
+						Retries:     attempt,
+						NotModified: true,
+					}
+					return
+				}
+				lastErr = err
+
+				// Retrying a deleted or disallowed article won't bring it back
+				var blocked *polite.RobotsBlockedError
+				if isGoneError(err) || errors.As(err, &blocked) {
+					results <- FetchResult{
+						Article: nil,
+						Error:   err,
+						URL:     articleURL,
+						Retries: attempt,
+					}
+					return
+				}
+
+				// Neither is a login wall, unless the session expired and a new one gets past it
+				var authErr *AuthRequiredError
+				if errors.As(err, &authErr) && (renewed || !site.renewSession(logins)) {
+					results <- FetchResult{
+						Article: nil,
+						Error:   err,
+						URL:     articleURL,
+						Retries: attempt,
+					}
+					return
+				}
+				renewed = renewed || authErr != nil
+			}
+
+			// All retries failed
+			results <- FetchResult{
+				Article: nil,
+				Error:   lastErr,
+				URL:     articleURL,
+				Retries: config.MaxRetries,
+			}
+		}(url)
+	}
+
+	// Close results channel when all goroutines complete
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// scrapeFullArticle downloads and parses an article. When cached validators are given the request
+// is conditional and an unchanged page comes back as errNotModified without being parsed. A kept
+// page is parsed in place of downloading it.
+func scrapeFullArticle(articleURL string, config Config, cached CacheValidators, kept *keptPage) (*Article, CacheValidators, error) {
+	profile := config.Profile
+	c := colly.NewCollector(
+		colly.Async(true),
+	)
+	if kept != nil {
+		c.WithTransport(kept)
+	}
+	// The user agent robots.txt was checked for
+	c.UserAgent = config.Robots.UserAgent()
+	c.SetRequestTimeout(config.RequestTimeout)
+	if jar := config.Site.cookieJar(); jar != nil {
+		c.SetCookieJar(jar)
+	}
+
+	// Limit concurrent requests per domain
+	c.Limit(&colly.LimitRule{
+		DomainGlob:  "*",
+		Parallelism: 2,
+		Delay:       100 * time.Millisecond,
+	})
+
+	// Add realistic headers
+	c.OnRequest(func(r *colly.Request) {
+		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
+		r.Headers.Set("Accept-Language", "en-US,en;q=0.5")
+		r.Headers.Set("Accept-Encoding", "gzip, deflate, br")
+		r.Headers.Set("DNT", "1")
+		r.Headers.Set("Connection", "keep-alive")
+		r.Headers.Set("Upgrade-Insecure-Requests", "1")
+		config.Site.authorize(*r.Headers, r.URL.String())
+
+		if cached.ETag != "" {
+			r.Headers.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			r.Headers.Set("If-Modified-Since", cached.LastModified)
+		}
+	})
+
+	var article Article
+	var fresh CacheValidators
+	var scrapeErr error
+	dates := newArticleDates()
+
+	c.OnResponse(func(r *colly.Response) {
+		fresh.ETag = r.Headers.Get("ETag")
+		fresh.LastModified = r.Headers.Get("Last-Modified")
+
+		// Gated articles redirect anonymous and expired sessions to the sign-in page
+		if final := r.Request.URL.String(); final != articleURL && profile.IsLoginURL(final) {
+			scrapeErr = &AuthRequiredError{URL: articleURL, Reason: "redirected to " + final}
+		}
+	})
+
+	// A login form where the article should be is a login wall served in place
+	loginForm := false
+	if len(profile.LoginForm) > 0 {
+		c.OnHTML(strings.Join(profile.LoginForm, ", "), func(e *colly.HTMLElement) {
+			loginForm = true
+		})
+	}
+
+	article.URL = articleURL
+
+	article.ID = articleIDFromURL(articleURL)
+	article.Locale = localeFromURL(articleURL)
+	if article.Locale == "" {
+		// Sites without locales in their URLs are in the primary crawl locale
+		article.Locale = config.Locales[0]
+	}
+	config.Site.tag(&article)
+
+	// Translations of the page; x-default is the locale picker, not a translation
+	c.OnHTML(`link[rel="alternate"][hreflang]`, func(e *colly.HTMLElement) {
+		locale := strings.ToLower(strings.TrimSpace(e.Attr("hreflang")))
+		href := e.Request.AbsoluteURL(e.Attr("href"))
+		if urlLocale := localeFromURL(href); urlLocale != "" {
+			locale = urlLocale
+		}
+		if locale == "" || locale == "x-default" || locale == article.Locale || href == "" {
+			return
+		}
+		article.Translations = append(article.Translations, Translation{Locale: locale, URL: href, ArticleID: articleIDFromURL(href)})
+	})
+
+	// The title comes from the profile's selectors, tried in order; hidden elements, metadata
+	// lines and page chrome are skipped
+	for _, selector := range profile.Title {
+		c.OnHTML(selector, func(e *colly.HTMLElement) {
+			if article.Title == "" && !strings.Contains(e.Attr("style"), "display: none") {
+				article.Title = profile.titleLine(e.Text)
+			}
+		})
+	}
+
+	// The main article content with full HTML, from the first body selector that matches
+	for _, selector := range profile.Body {
+		c.OnHTML(selector, func(e *colly.HTMLElement) {
+			if article.Body == "" {
+				for _, strip := range profile.Strip {
+					e.DOM.Find(strip).Remove()
+				}
+				if body, err := e.DOM.Html(); err == nil {
+					setBody(&article, body)
+				}
+			}
+		})
+	}
+
+	// Zendesk breadcrumbs link to the category and section pages, whose URLs carry their IDs.
+	// The innermost section is the article's own; the first link is the home page and is skipped.
+	if len(profile.Breadcrumbs) > 0 {
+		crumbs := 0
+		c.OnHTML(strings.Join(profile.Breadcrumbs, ", "), func(e *colly.HTMLElement) {
+			name := strings.TrimSpace(e.Text)
+			href := e.Attr("href")
+			crumbs++
+			if name == "" {
+				return
+			}
+
+			if m := categoryIDPattern.FindStringSubmatch(href); m != nil {
+				article.CategoryID, _ = strconv.ParseInt(m[1], 10, 64)
+				article.CategoryName = name
+				article.Breadcrumbs = append(article.Breadcrumbs, name)
+			} else if m := sectionIDPattern.FindStringSubmatch(href); m != nil {
+				article.SectionID, _ = strconv.ParseInt(m[1], 10, 64)
+				article.SectionName = name
+				article.Breadcrumbs = append(article.Breadcrumbs, name)
+			} else if crumbs > 1 {
+				article.Breadcrumbs = append(article.Breadcrumbs, name)
+			}
+		})
+	}
+
+	// Dates, from every place a page may carry them; articleDates.apply picks the most trustworthy
+	c.OnHTML(`script[type="application/ld+json"]`, func(e *colly.HTMLElement) {
+		var data interface{}
+		if err := json.Unmarshal([]byte(e.Text), &data); err == nil {
+			published, modified := jsonLDDates(data)
+			dates.add(dateSourceJSONLD, published, modified)
+		}
+	})
+
+	c.OnHTML("meta[property], meta[name], meta[itemprop]", func(e *colly.HTMLElement) {
+		key := strings.ToLower(e.Attr("property") + e.Attr("name") + e.Attr("itemprop"))
+		switch key {
+		case "article:published_time", "datepublished", "date", "dc.date.issued":
+			dates.add(dateSourceMeta, e.Attr("content"), "")
+		case "article:modified_time", "og:updated_time", "datemodified", "last-modified":
+			dates.add(dateSourceMeta, "", e.Attr("content"))
+		}
+	})
+
+	// The first date element is the publish date and the last the update, as Zendesk themes
+	// render their <time> elements
+	if len(profile.Dates) > 0 {
+		c.OnHTML(strings.Join(profile.Dates, ", "), func(e *colly.HTMLElement) {
+			date := e.Attr("datetime")
+			if date == "" {
+				date = strings.TrimSpace(e.Text)
+			}
+			dates.add(dateSourceTime, date, "")
+			dates.setModified(dateSourceTime, date)
+		})
+	}
+
+	// The "Published … • Last Updated …" line the title parser skips
+	if len(profile.DateText) > 0 {
+		c.OnHTML(strings.Join(profile.DateText, ", "), func(e *colly.HTMLElement) {
+			text := collapseWhitespace(e.Text)
+			var published, modified string
+			if m := publishedTextPattern.FindStringSubmatch(text); m != nil {
+				published = m[1]
+			}
+			if m := updatedTextPattern.FindStringSubmatch(text); m != nil {
+				modified = m[1]
+			}
+			dates.add(dateSourceHeader, published, modified)
+		})
+	}
+
+	c.OnError(func(r *colly.Response, err error) {
+		if r != nil && r.StatusCode == http.StatusNotModified {
+			scrapeErr = errNotModified
+			return
+		}
+		if r != nil && r.StatusCode == http.StatusUnauthorized {
+			scrapeErr = &AuthRequiredError{URL: articleURL, Reason: "HTTP 401"}
+			return
+		}
+		if r != nil && r.StatusCode != 0 {
+			statusErr := &polite.HTTPStatusError{URL: articleURL, StatusCode: r.StatusCode, Message: err.Error()}
+			if r.Headers != nil {
+				statusErr.RetryAfter = polite.ParseRetryAfter(r.Headers.Get("Retry-After"))
+			}
+			scrapeErr = statusErr
+			return
+		}
+		scrapeErr = err
+	})
+
+	// Visit the URL
+	if err := c.Visit(articleURL); err != nil {
+		return nil, fresh, fmt.Errorf("error visiting page: %v", err)
+	}
+
+	// Wait for async operations to complete
+	c.Wait()
+
+	if errors.Is(scrapeErr, errNotModified) {
+		return nil, cached, errNotModified
+	}
+	if scrapeErr != nil {
+		return nil, fresh, fmt.Errorf("scraping error: %w", scrapeErr)
+	}
+
+	if article.Title == "" && loginForm {
+		return nil, fresh, &AuthRequiredError{URL: articleURL, Reason: "the page shows a login form"}
+	}
+	if article.Title == "" {
+		return nil, fresh, fmt.Errorf("no meaningful title found on page")
+	}
+
+	if article.Body == "" {
+		return nil, fresh, fmt.Errorf("no content found on page")
+	}
+
+	// Missing dates stay empty and the article is flagged; stamping the crawl time would make
+	// every undated article look brand new to the recency boost
+	dates.apply(&article)
+
+	return &article, fresh, nil
+}
+
+// Provenance of an article's dates, in the order they are trusted
+const (
+	dateSourceAPI     = "zendesk-api"
+	dateSourceJSONLD  = "json-ld"
+	dateSourceMeta    = "meta"
+	dateSourceTime    = "time-element"
+	dateSourceHeader  = "header-text"
+	dateSourceSitemap = "sitemap-lastmod"
+)
+
+var pageDateSources = []string{dateSourceJSONLD, dateSourceMeta, dateSourceTime, dateSourceHeader}
+
+var (
+	publishedTextPattern = regexp.MustCompile(`(?i)\bpublished\s*(?:on\s*)?:?\s*([^•|]+)`)
+	updatedTextPattern   = regexp.MustCompile(`(?i)\b(?:last\s+)?updated\s*(?:on\s*)?:?\s*([^•|]+)`)
+)
+
+// articleDates collects the published and modified dates a page offers, per source
+type articleDates struct {
+	mu        sync.Mutex
+	published map[string]string
+	modified  map[string]string
+}
+
+func newArticleDates() *articleDates {
+	return &articleDates{published: make(map[string]string), modified: make(map[string]string)}
+}
+
+// add keeps the first valid date each source offers; unparseable values are ignored
+func (d *articleDates) add(source, published, modified string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if date := normalizeDate(published); date != "" && d.published[source] == "" {
+		d.published[source] = date
+	}
+	if date := normalizeDate(modified); date != "" && d.modified[source] == "" {
+		d.modified[source] = date
+	}
+}
+
+// setModified overwrites the source's modified date, for sources where the last value wins
+func (d *articleDates) setModified(source, modified string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if date := normalizeDate(modified); date != "" {
+		d.modified[source] = date
+	}
+}
+
+// apply sets the article's dates from the most trustworthy source that has each one
+func (d *articleDates) apply(article *Article) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, source := range pageDateSources {
+		if article.CreatedAt == "" && d.published[source] != "" {
+			article.CreatedAt, article.CreatedAtSource = d.published[source], source
+		}
+		if article.UpdatedAt == "" && d.modified[source] != "" {
+			article.UpdatedAt, article.UpdatedAtSource = d.modified[source], source
+		}
+	}
+	article.DatesUnreliable = article.CreatedAt == "" && article.UpdatedAt == ""
+}
+
+// jsonLDDates finds datePublished/dateModified in a JSON-LD block, including @graph arrays
+func jsonLDDates(data interface{}) (published, modified string) {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		published, _ = v["datePublished"].(string)
+		modified, _ = v["dateModified"].(string)
+		if published != "" || modified != "" {
+			return published, modified
+		}
+		if graph, ok := v["@graph"]; ok {
+			return jsonLDDates(graph)
+		}
+	case []interface{}:
+		for _, item := range v {
+			if published, modified = jsonLDDates(item); published != "" || modified != "" {
+				return published, modified
+			}
+		}
+	}
+	return "", ""
+}
+
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+	time.RFC1123,
+	time.RFC1123Z,
+}
+
+// normalizeDate parses a machine or human-written date into RFC 3339 UTC. Values that can't be
+// parsed, or that lie in the future, come back empty so they are never mistaken for real dates.
+func normalizeDate(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+
+	var parsed time.Time
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			parsed = t
+			break
+		}
+	}
+	if parsed.IsZero() {
+		day := parseLooseDate(value)
+		if day == "" {
+			return ""
+		}
+		parsed, _ = time.Parse("2006-01-02", day)
+	}
+
+	if parsed.Year() < 1995 || parsed.After(time.Now().Add(48*time.Hour)) {
+		return ""
+	}
+	return parsed.UTC().Format(time.RFC3339)
+}
+
+// buildSinks selects the sinks listed in CRAWL_SINKS (comma separated). Without it the crawler
+// keeps its old behaviour of indexing to Elasticsearch unless ELASTICSEARCH_ENABLED=false.
+func buildSinks(esConfig sink.ElasticsearchConfig, state *CrawlState) []sink.Sink {
+	defaultSinks := ""
+	if esConfig.Enabled {
+		defaultSinks = "elasticsearch"
+	}
+
+	var sinks []sink.Sink
+	for _, name := range strings.Split(getEnv("CRAWL_SINKS", defaultSinks), ",") {
+		switch strings.TrimSpace(strings.ToLower(name)) {
+		case "":
+			continue
+		case "elasticsearch", "es":
+			sinks = append(sinks, sink.NewElasticsearchSink(esConfig, state, releaseEntries))
+		case "azure":
+			sinks = append(sinks, sink.NewAzureSearchSink(sink.AzureSearchConfig{
+				ServiceName: getEnv("AZURE_SEARCH_SERVICE", ""),
+				APIKey:      getEnv("AZURE_SEARCH_KEY", ""),
+				IndexName:   getEnv("AZURE_SEARCH_INDEX", "talkdesk-docs"),
+				APIVersion:  getEnv("AZURE_SEARCH_API_VERSION", "2021-04-30-Preview"),
+				BatchSize:   getEnvInt("AZURE_SEARCH_BATCH_SIZE", 100),
+			}))
+		case "jsonl":
+			sinks = append(sinks, sink.NewJSONLSink(getEnv("JSONL_OUTPUT_PATH", "crawl-output.jsonl")))
+		case "stdout":
+			sinks = append(sinks, sink.NewStdoutSink(articleOutput))
+		default:
+			fmt.Printf("⚠ Unknown sink %q in CRAWL_SINKS, ignoring\n", name)
+		}
+	}
+
+	return sinks
+}
+
+// reconcileIndex compares every live document in the index against the IDs found in this crawl
+// and deletes (or tombstones with deleted_at) the ones that are gone
+func reconcileIndex(config sink.ElasticsearchConfig, liveIDs map[string]bool, mode string) (*ReconcileSummary, error) {
+	if mode != "delete" && mode != "tombstone" {
+		return nil, fmt.Errorf("unknown RECONCILE_MODE %q (expected delete, tombstone or off)", mode)
+	}
+
+	indexed, err := fetchIndexedDocuments(config)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &ReconcileSummary{Mode: mode, Indexed: len(indexed)}
+
+	var stale []IndexedDocument
+	for _, doc := range indexed {
+		if !liveIDs[doc.ID] {
+			stale = append(stale, doc)
+		}
+	}
+
+	// Refuse to wipe out a large part of the index in one go; that is almost always a
+	// broken sitemap rather than a mass deletion upstream
+	maxRatio := getEnvFloat("RECONCILE_MAX_RATIO", 0.5)
+	if len(indexed) > 0 && float64(len(stale))/float64(len(indexed)) > maxRatio {
+		return summary, fmt.Errorf("%d of %d indexed articles look stale, above RECONCILE_MAX_RATIO=%.2f; not removing anything",
+			len(stale), len(indexed), maxRatio)
+	}
+
+	removeStale(config, summary, stale)
+	return summary, nil
+}
+
+// removeDocuments deletes (or tombstones) exactly the given documents, for sources that report
+// removals themselves instead of listing everything that is still live
+func removeDocuments(config sink.ElasticsearchConfig, docs []IndexedDocument, mode string) (*ReconcileSummary, error) {
+	if mode != "delete" && mode != "tombstone" {
+		return nil, fmt.Errorf("unknown RECONCILE_MODE %q (expected delete, tombstone or off)", mode)
+	}
+
+	summary := &ReconcileSummary{Mode: mode, Indexed: len(docs)}
+	removeStale(config, summary, docs)
+	return summary, nil
+}
+
+// removeStale deletes or tombstones each document, and the release entries of those removed,
+// collecting the outcome in summary
+func removeStale(config sink.ElasticsearchConfig, summary *ReconcileSummary, stale []IndexedDocument) {
+	mode := summary.Mode
+	client := &http.Client{Timeout: 10 * time.Second}
+	deletedAt := time.Now().UTC().Format(time.RFC3339)
+
+	for _, doc := range stale {
+		var req *http.Request
+		var err error
+		if mode == "tombstone" {
+			payload := fmt.Sprintf(`{"doc":{"deleted_at":%q}}`, deletedAt)
+			req, err = sink.NewRequest(config, "POST", fmt.Sprintf("%s/%s/_update/%s", config.URL, config.Index, doc.ID), strings.NewReader(payload))
+		} else {
+			req, err = sink.NewRequest(config, "DELETE", fmt.Sprintf("%s/%s/_doc/%s", config.URL, config.Index, doc.ID), nil)
+		}
+		if err != nil {
+			summary.Failures = append(summary.Failures, fmt.Errorf("failed to build %s request for %s: %v", mode, doc.ID, err))
+			continue
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			summary.Failures = append(summary.Failures, fmt.Errorf("failed to %s %s: %v", mode, doc.ID, err))
+			continue
+		}
+		resp.Body.Close()
+
+		// 404 on delete means someone else already removed it, which is what we wanted
+		if resp.StatusCode == 404 || (resp.StatusCode >= 200 && resp.StatusCode < 300) {
+			summary.Removed = append(summary.Removed, doc)
+			continue
+		}
+		summary.Failures = append(summary.Failures, fmt.Errorf("%s of %s failed with status %d", mode, doc.ID, resp.StatusCode))
+	}
+
+	// Release entries are derived from their article and go with it, in either mode
+	if config.ReleasesIndex != "" && len(summary.Removed) > 0 {
+		var removedIDs []string
+		for _, doc := range summary.Removed {
+			removedIDs = append(removedIDs, doc.ID)
+		}
+		if err := sink.DeleteReleaseEntries(config, removedIDs, nil); err != nil {
+			summary.Failures = append(summary.Failures, fmt.Errorf("failed to delete release entries of removed articles: %v", err))
+		}
+	}
+}
+
+// fetchIndexedDocuments pages through every document that has not already been tombstoned
+func fetchIndexedDocuments(config sink.ElasticsearchConfig) ([]IndexedDocument, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	searchURL := fmt.Sprintf("%s/%s/_search", config.URL, config.Index)
+
+	var docs []IndexedDocument
+	var searchAfter []interface{}
+
+	for {
+		query := map[string]interface{}{
+			"size":    1000,
+			"_source": []string{"id", "title", "url"},
+			"query": map[string]interface{}{
+				"bool": map[string]interface{}{
+					"must_not": []map[string]interface{}{
+						{"exists": map[string]interface{}{"field": "deleted_at"}},
+					},
+				},
+			},
+			"sort": []map[string]interface{}{
+				{"id": map[string]string{"order": "asc"}},
+			},
+		}
+		if searchAfter != nil {
+			query["search_after"] = searchAfter
+		}
+
+		jsonData, err := json.Marshal(query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal index scan query: %v", err)
+		}
+
+		req, err := sink.NewRequest(config, "POST", searchURL, bytes.NewReader(jsonData))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create index scan request: %v", err)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan index: %v", err)
+		}
+
+		var searchResp struct {
+			Hits struct {
+				Hits []struct {
+					ID     string          `json:"_id"`
+					Source IndexedDocument `json:"_source"`
+					Sort   []interface{}   `json:"sort"`
+				} `json:"hits"`
+			} `json:"hits"`
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			resp.Body.Close()
+			return nil, fmt.Errorf("index scan failed with status %d", resp.StatusCode)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&searchResp)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode index scan response: %v", err)
+		}
+
+		hits := searchResp.Hits.Hits
+		for _, hit := range hits {
+			doc := hit.Source
+			// The document _id is what we delete by, even if the id field is missing
+			doc.ID = hit.ID
+			docs = append(docs, doc)
+		}
+
+		if len(hits) < 1000 {
+			return docs, nil
+		}
+		searchAfter = hits[len(hits)-1].Sort
+	}
+}
+
+func printReconcileSummary(summary *ReconcileSummary) {
+	verb := "Deleted"
+	if summary.Mode == "tombstone" {
+		verb = "Tombstoned"
+	}
+
+	fmt.Printf("\n=== Reconciliation ===\n")
+	fmt.Printf("Indexed articles checked: %d\n", summary.Indexed)
+	fmt.Printf("%s: %d articles\n", verb, len(summary.Removed))
+	for i, doc := range summary.Removed {
+		if i == 20 {
+			fmt.Printf("- ... and %d more\n", len(summary.Removed)-20)
+			break
+		}
+		fmt.Printf("- %s %s (%s)\n", doc.ID, doc.Title, doc.URL)
+	}
+
+	if len(summary.Failures) > 0 {
+		fmt.Printf("Removal failures: %d\n", len(summary.Failures))
+		for _, err := range summary.Failures {
+			fmt.Printf("- %v\n", err)
+		}
+	}
+}
+
+// isGoneError reports whether a fetch failed because the article no longer exists
+func isGoneError(err error) bool {
+	var statusErr *polite.HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == 404 || statusErr.StatusCode == 410
+	}
+	return false
+}
+
+// articleIDFromURL returns the document ID of an article URL, see Site.documentID
+func articleIDFromURL(articleURL string) string {
+	return siteForURL(articleURL).documentID(zendeskIDFromURL(articleURL), localeFromURL(articleURL))
+}
+
+// zendeskIDFromURL returns the article ID the site's profile reads from the URL, for Zendesk
+// the numeric article ID, which translations share
+func zendeskIDFromURL(articleURL string) string {
+	pattern := articleIDPattern
+	if site := siteForURL(articleURL); site != nil {
+		pattern = site.profile.idPattern
+	}
+	matches := pattern.FindStringSubmatch(articleURL)
+	if len(matches) >= 2 {
+		return matches[1]
+	}
+	return ""
+}
+
+// bareIDLocale is the locale whose documents keep the plain Zendesk article ID, so indexes built
+// before other locales were crawled keep their document IDs
+const bareIDLocale = "en-us"
+
+// documentID makes the Zendesk article ID unique across locales: translations share the Zendesk
+// ID, so outside en-us it gets the locale appended, as in 360001234-pt-br
+func documentID(zendeskID, locale string) string {
+	if zendeskID == "" || locale == "" || locale == bareIDLocale {
+		return zendeskID
+	}
+	return zendeskID + "-" + locale
+}
+
+// localeFromURL returns the lowercased help center locale of a URL, empty when it has none
+func localeFromURL(pageURL string) string {
+	if m := localePattern.FindStringSubmatch(pageURL); m != nil {
+		return strings.ToLower(m[1])
+	}
+	return ""
+}
+
+// parseLocales reads a comma-separated list of locales, lowercased and without duplicates
+func parseLocales(list string) []string {
+	var locales []string
+	seen := make(map[string]bool)
+	for _, locale := range strings.Split(list, ",") {
+		locale = strings.ToLower(strings.TrimSpace(locale))
+		if locale != "" && !seen[locale] {
+			seen[locale] = true
+			locales = append(locales, locale)
+		}
+	}
+	if len(locales) == 0 {
+		locales = []string{bareIDLocale}
+	}
+	return locales
+}
+
+// splitList splits a comma-separated setting into its non-empty entries
+func splitList(list string) []string {
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+var localePattern = regexp.MustCompile(`(?i)/hc/([a-z]{2,3}(?:-[a-z0-9]{2,4})?)/`)
+
+var categoryIDPattern = regexp.MustCompile(`/categories/(\d+)`)
+
+var sectionIDPattern = regexp.MustCompile(`/sections/(\d+)`)
+
+var articleIDPattern = regexp.MustCompile(`/articles/(\d+)`)
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Host
+}
+
+// openCheckpoint starts a fresh checkpoint, or with resume loads the previous one and keeps appending
+func openCheckpoint(path string, resume bool) (*Checkpoint, error) {
+	checkpoint := &Checkpoint{
+		path:      path,
+		completed: make(map[string]bool),
+		failed:    make(map[string]string),
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		if err := checkpoint.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint %s: %v", path, err)
+	}
+	checkpoint.file = file
+
+	return checkpoint, nil
+}
+
+func (c *Checkpoint) load() error {
+	file, err := os.Open(c.path)
+	if os.IsNotExist(err) {
+		fmt.Printf("📂 No checkpoint at %s, nothing to resume\n", c.path)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read checkpoint %s: %v", c.path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record checkpointRecord
+		// A crash can leave a half-written last line, skip anything unparsable
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.URL == "" {
+			continue
+		}
+
+		// Later records win, so a URL that failed and then succeeded counts as completed
+		if record.Status == "failed" {
+			delete(c.completed, record.URL)
+			c.failed[record.URL] = record.Error
+		} else {
+			delete(c.failed, record.URL)
+			c.completed[record.URL] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read checkpoint %s: %v", c.path, err)
+	}
+
+	fmt.Printf("📂 Loaded checkpoint: %d completed, %d failed URLs\n", len(c.completed), len(c.failed))
+	return nil
+}
+
+// Remaining filters out URLs the checkpoint already completed and puts failed ones first
+func (c *Checkpoint) Remaining(urls []string) ([]string, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var failed, rest []string
+	skipped := 0
+	for _, u := range urls {
+		if c.completed[u] {
+			skipped++
+			continue
+		}
+		if _, wasFailed := c.failed[u]; wasFailed {
+			failed = append(failed, u)
+			continue
+		}
+		rest = append(rest, u)
+	}
+
+	return append(failed, rest...), skipped
+}
+
+func (c *Checkpoint) FailedCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.failed)
+}
+
+// Mark queues a record; it reaches disk on the next Commit
+func (c *Checkpoint) Mark(url, status string, err error) {
+	record := checkpointRecord{URL: url, Status: status, At: time.Now().UTC()}
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = append(c.pending, record)
+}
+
+// Commit appends queued records and syncs them to disk
+func (c *Checkpoint) Commit() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.pending) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range c.pending {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("failed to encode checkpoint record: %v", err)
+		}
+	}
+
+	if _, err := c.file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to append to checkpoint %s: %v", c.path, err)
+	}
+	if err := c.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync checkpoint %s: %v", c.path, err)
+	}
+
+	c.pending = nil
+	return nil
+}
+
+func (c *Checkpoint) Close() error {
+	if err := c.Commit(); err != nil {
+		return err
+	}
+	return c.file.Close()
+}
+
+func loadCrawlState(path string) (*CrawlState, error) {
+	state := &CrawlState{
+		path:    path,
+		Version: crawlStateVersion,
+		Entries: make(map[string]*CrawlStateEntry),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		fmt.Printf("📂 No crawl state at %s, every article will be fetched\n", path)
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read crawl state %s: %v", path, err)
+	}
+
+	// A state written before versioning has no version field and counts as version 0
+	state.Version = 0
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse crawl state %s: %v", path, err)
+	}
+	if state.Entries == nil {
+		state.Entries = make(map[string]*CrawlStateEntry)
+	}
+
+	fmt.Printf("📂 Loaded crawl state for %d articles from %s\n", len(state.Entries), path)
+	return state, nil
+}
+
+// Outdated reports whether previously crawled articles were indexed in an older document format
+func (s *CrawlState) Outdated() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Version < crawlStateVersion && len(s.Entries) > 0
+}
+
+// MarkCurrent records that every article has been indexed in the current format
+func (s *CrawlState) MarkCurrent() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Version = crawlStateVersion
+}
+
+// NeedsFetch reports whether a sitemap entry is new or its lastmod differs from the last fetch
+func (s *CrawlState) NeedsFetch(u URL) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, exists := s.Entries[u.Loc]
+	if !exists {
+		return true
+	}
+	// Without a lastmod we have no change signal, so always re-fetch
+	if u.LastMod == "" {
+		return true
+	}
+	return entry.LastMod != u.LastMod
+}
+
+// Validators returns the ETag/Last-Modified seen on the last fetch of a URL, if any
+func (s *CrawlState) Validators(articleURL string) CacheValidators {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, exists := s.Entries[articleURL]; exists {
+		return entry.CacheValidators
+	}
+	return CacheValidators{}
+}
+
+// Touch records a 304 response: the content and validators stay, the new lastmod is remembered
+func (s *CrawlState) Touch(articleURL, lastMod string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, exists := s.Entries[articleURL]; exists {
+		entry.LastMod = lastMod
+		entry.LastFetched = time.Now().UTC()
+	}
+}
+
+// Changed reports whether a fetched article differs from what was last recorded for its URL
+func (s *CrawlState) Changed(articleURL string, article *Article) bool {
+	hash := contentHash(article)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, exists := s.Entries[articleURL]
+	return !exists || entry.ContentHash != hash || entry.ID != article.ID
+}
+
+// Record stores the result of a fetch once its article has been written (or needed no write)
+func (s *CrawlState) Record(articleURL, lastMod string, article *Article, validators CacheValidators) {
+	hash := contentHash(article)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, exists := s.Entries[articleURL]
+
+	updated := &CrawlStateEntry{
+		ID:              article.ID,
+		LastMod:         lastMod,
+		ContentHash:     hash,
+		LastFetched:     time.Now().UTC(),
+		CacheValidators: validators,
+		InternalLinks:   article.InternalLinks,
+		ExternalLinks:   article.ExternalLinks,
+		SimHash:         article.SimHash,
+	}
+	// What the state knew of the indexed document stays, unless it was indexed under another ID
+	if exists && (entry.ID == "" || entry.ID == article.ID) {
+		updated.LinkedFrom = entry.LinkedFrom
+		updated.DuplicateOf = entry.DuplicateOf
+		updated.Revision = entry.Revision
+		updated.RevisionHash = entry.RevisionHash
+	}
+	if article.RevisionHash != "" {
+		updated.Revision = article.Revision
+		updated.RevisionHash = article.RevisionHash
+	}
+	s.Entries[articleURL] = updated
+}
+
+// MovedIDs returns the article URLs whose document ID changed since they were last written,
+// because their site's id_prefix did, with the ID they were written under. They have to be
+// written again under the new ID before the old document can go.
+func (s *CrawlState) MovedIDs() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	moved := make(map[string]string)
+	for articleURL, entry := range s.Entries {
+		if entry.ID != "" && entry.ID != articleIDFromURL(articleURL) {
+			moved[articleURL] = entry.ID
+		}
+	}
+	return moved
+}
+
+// DocumentID returns the ID an article URL was last written under, empty when the state doesn't
+// know it
+func (s *CrawlState) DocumentID(articleURL string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, exists := s.Entries[articleURL]; exists {
+		return entry.ID
+	}
+	return ""
+}
+
+// Revision returns the number and hash of the latest stored revision of an article, zero when
+// the state doesn't know it under that document ID
+func (s *CrawlState) Revision(articleURL, documentID string) (int, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, exists := s.Entries[articleURL]; exists && (entry.ID == "" || entry.ID == documentID) {
+		return entry.Revision, entry.RevisionHash
+	}
+	return 0, ""
+}
+
+// ArticleLinks returns the recorded links of the given article URLs, or of every article when
+// urls is nil
+func (s *CrawlState) ArticleLinks(urls map[string]bool) []articleLinks {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var articles []articleLinks
+	for articleURL, entry := range s.Entries {
+		if urls != nil && !urls[articleURL] {
+			continue
+		}
+		id := articleIDFromURL(articleURL)
+		if id == "" {
+			continue
+		}
+		articles = append(articles, articleLinks{URL: articleURL, ID: id, Internal: entry.InternalLinks, External: entry.ExternalLinks})
+	}
+	sort.Slice(articles, func(i, j int) bool { return articles[i].ID < articles[j].ID })
+	return articles
+}
+
+func (s *CrawlState) LinkedFrom(articleURL string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, exists := s.Entries[articleURL]; exists {
+		return entry.LinkedFrom
+	}
+	return nil
+}
+
+func (s *CrawlState) SetLinkedFrom(articleURL string, ids []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, exists := s.Entries[articleURL]; exists {
+		entry.LinkedFrom = ids
+	}
+}
+
+// Fingerprints returns the recorded simhash of the given article URLs, or of every article when
+// urls is nil. Articles without one are left out.
+func (s *CrawlState) Fingerprints(urls map[string]bool) []articleFingerprint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var articles []articleFingerprint
+	for articleURL, entry := range s.Entries {
+		if (urls != nil && !urls[articleURL]) || entry.SimHash == "" {
+			continue
+		}
+		hash, err := strconv.ParseUint(entry.SimHash, 16, 64)
+		id := articleIDFromURL(articleURL)
+		if err != nil || id == "" {
+			continue
+		}
+		articles = append(articles, articleFingerprint{URL: articleURL, ID: id, SimHash: hash})
+	}
+	sort.Slice(articles, func(i, j int) bool { return lessArticleID(articles[i].ID, articles[j].ID) })
+	return articles
+}
+
+func (s *CrawlState) DuplicateOf(articleURL string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, exists := s.Entries[articleURL]; exists {
+		return entry.DuplicateOf
+	}
+	return ""
+}
+
+func (s *CrawlState) SetDuplicateOf(articleURL, canonicalID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, exists := s.Entries[articleURL]; exists {
+		entry.DuplicateOf = canonicalID
+	}
+}
+
+// Forget drops a URL whose article no longer exists upstream
+func (s *CrawlState) Forget(articleURL string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.Entries, articleURL)
+}
+
+// Save writes the state to a temp file and renames it so a crash never leaves a truncated file
+func (s *CrawlState) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal crawl state: %v", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write crawl state: %v", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace crawl state: %v", err)
+	}
+
+	return nil
+}
+
+// isReleaseNotes reports whether an article announces releases, going by its title or where it lives
+func isReleaseNotes(article *Article) bool {
+	if strings.Contains(strings.ToLower(article.Title), "release notes") {
+		return true
+	}
+	for _, crumb := range append([]string{article.SectionName}, article.Breadcrumbs...) {
+		if strings.Contains(strings.ToLower(crumb), "release notes") {
+			return true
+		}
+	}
+	return false
+}
+
+// releaseEntryTypes maps the labels writers put in front of an entry to the four types we index
+var releaseEntryTypes = map[string]string{
+	"new":          "New",
+	"new feature":  "New",
+	"feature":      "New",
+	"added":        "New",
+	"introducing":  "New",
+	"improved":     "Improved",
+	"improvement":  "Improved",
+	"enhancement":  "Improved",
+	"enhanced":     "Improved",
+	"updated":      "Improved",
+	"changed":      "Improved",
+	"fixed":        "Fixed",
+	"fix":          "Fixed",
+	"bug fix":      "Fixed",
+	"bugfix":       "Fixed",
+	"resolved":     "Fixed",
+	"deprecated":   "Deprecated",
+	"deprecation":  "Deprecated",
+	"removed":      "Deprecated",
+	"retired":      "Deprecated",
+	"end of life":  "Deprecated",
+	"sunset":       "Deprecated",
+	"discontinued": "Deprecated",
+}
+
+// "New: Feature", "[Fixed] Feature" and "Improved – Feature" all carry a type label
+var releaseTypeLabelPattern = regexp.MustCompile(`^\[?([A-Za-z][A-Za-z ]{1,20}?)\]?\s*(?:[:\-–—|]\s*|\]\s*)(.+)$`)
+
+// Lines that describe rollout rather than the change itself
+var availabilityPattern = regexp.MustCompile(`(?i)^(availability|available|rollout|roll-out|rolling out|release date|released|ga date)\b\s*:?\s*(.*)$`)
+
+// releaseEntries is what the Elasticsearch sink indexes as release entries: the entries of a
+// release-notes article, none for any other
+func releaseEntries(article *Article) []ReleaseEntry {
+	if !isReleaseNotes(article) {
+		return nil
+	}
+	return parseReleaseEntries(article)
+}
+
+// parseReleaseEntries splits a release-notes article into its entries. When the body has h3
+// headings, h2s name the product area and each h3 is an entry; with only h2s each h2 is an entry.
+// An h2 that is just a date sets the release date of the entries under it instead.
+func parseReleaseEntries(article *Article) []ReleaseEntry {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(article.Body))
+	if err != nil {
+		return nil
+	}
+
+	entryLevel := "h3"
+	if doc.Find("h3").Length() == 0 {
+		entryLevel = "h2"
+	}
+
+	// Entry dates fall back to the month in the title ("Release Notes March 2024"), then the publish date
+	articleDate := parseLooseDate(article.Title)
+	if articleDate == "" && len(article.CreatedAt) >= 10 {
+		articleDate = article.CreatedAt[:10]
+	}
+
+	var entries []ReleaseEntry
+	productArea := ""
+	sectionDate := ""
+	indexedAt := time.Now().UTC().Format(time.RFC3339)
+
+	doc.Find("h2, h3").Each(func(_ int, heading *goquery.Selection) {
+		text := collapseWhitespace(heading.Text())
+		if text == "" {
+			return
+		}
+
+		if goquery.NodeName(heading) != entryLevel {
+			// An h2 above h3 entries: either a date or a product area
+			if date := parseLooseDate(text); date != "" && len(text) <= 40 {
+				sectionDate = date
+			} else {
+				productArea = text
+			}
+			return
+		}
+
+		entry := ReleaseEntry{
+			ID:           fmt.Sprintf("%s-%d", article.ID, len(entries)+1),
+			ArticleID:    article.ID,
+			ArticleTitle: article.Title,
+			ArticleURL:   article.URL,
+			URL:          article.URL,
+			Feature:      text,
+			ProductArea:  productArea,
+			IndexedAt:    indexedAt,
+		}
+		if anchor, ok := heading.Attr("id"); ok && anchor != "" {
+			entry.URL = article.URL + "#" + anchor
+		}
+
+		if m := releaseTypeLabelPattern.FindStringSubmatch(text); m != nil {
+			if entryType, ok := releaseEntryTypes[strings.ToLower(strings.TrimSpace(m[1]))]; ok {
+				entry.Type = entryType
+				entry.Feature = strings.TrimSpace(m[2])
+			}
+		}
+
+		var description []string
+		explicitDate := ""
+		heading.NextUntil("h1, h2, h3").Each(func(_ int, block *goquery.Selection) {
+			line := collapseWhitespace(block.Text())
+			if line == "" {
+				return
+			}
+			m := availabilityPattern.FindStringSubmatch(line)
+			if m == nil {
+				description = append(description, line)
+				return
+			}
+
+			label := strings.ToLower(m[1])
+			if label == "release date" || label == "released" || label == "ga date" {
+				explicitDate = parseLooseDate(m[2])
+				return
+			}
+			// Keep "Rolling out gradually from ..." readable rather than dropping the verb
+			if label == "rolling out" {
+				entry.Availability = line
+			} else {
+				entry.Availability = m[2]
+			}
+		})
+		entry.Description = strings.Join(description, "\n")
+
+		// The most specific date wins: explicit release date, rollout start, date heading, article
+		switch {
+		case explicitDate != "":
+			entry.ReleaseDate = explicitDate
+		case parseLooseDate(entry.Availability) != "":
+			entry.ReleaseDate = parseLooseDate(entry.Availability)
+		case sectionDate != "":
+			entry.ReleaseDate = sectionDate
+		default:
+			entry.ReleaseDate = articleDate
+		}
+
+		entries = append(entries, entry)
+	})
+
+	return entries
+}
+
+var looseDatePatterns = []struct {
+	pattern *regexp.Regexp
+	layouts []string
+}{
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}`), []string{"2006-01-02"}},
+	{regexp.MustCompile(`(?i)\b[A-Z][a-z]{2,8}\.? \d{1,2}(?:st|nd|rd|th)?, \d{4}\b`), []string{"January 2, 2006", "Jan 2, 2006", "Jan. 2, 2006"}},
+	{regexp.MustCompile(`(?i)\b\d{1,2}(?:st|nd|rd|th)? [A-Z][a-z]{2,8} \d{4}\b`), []string{"2 January 2006", "2 Jan 2006"}},
+	{regexp.MustCompile(`(?i)\b[A-Z][a-z]{2,8} \d{4}\b`), []string{"January 2006", "Jan 2006"}},
+}
+
+var ordinalSuffixPattern = regexp.MustCompile(`(\d)(st|nd|rd|th)\b`)
+
+// parseLooseDate finds the first human-written date in text and returns it as YYYY-MM-DD.
+// A month without a day ("March 2024") becomes the first of the month.
+func parseLooseDate(text string) string {
+	for _, candidate := range looseDatePatterns {
+		match := candidate.pattern.FindString(text)
+		if match == "" {
+			continue
+		}
+		match = ordinalSuffixPattern.ReplaceAllString(match, "$1")
+		for _, layout := range candidate.layouts {
+			if t, err := time.Parse(layout, match); err == nil {
+				return t.Format("2006-01-02")
+			}
+		}
+	}
+	return ""
+}
+
+func collapseWhitespace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func contentHash(article *Article) string {
+	sum := sha256.Sum256([]byte(article.Title + "\x00" + article.Body))
+	return hex.EncodeToString(sum[:])
+}
+
+// simHashShingle is the number of consecutive words hashed together by simHash, and
+// simHashMinWords the body length below which no fingerprint is taken: stubs like "Coming
+// soon" would otherwise all be duplicates of each other.
+const (
+	simHashShingle  = 3
+	simHashMinWords = 20
+)
+
+// simHash fingerprints text as 64 bits where similar texts differ in few bits. Each shingle of
+// words votes on every bit with its FNV hash; a bit is set when most shingles have it set.
+func simHash(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) < simHashMinWords {
+		return ""
+	}
+
+	var votes [64]int
+	for i := 0; i+simHashShingle <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+simHashShingle], " ")))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				votes[bit]++
+			} else {
+				votes[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit, vote := range votes {
+		if vote > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fmt.Sprintf("%016x", fingerprint)
+}
+
+// Elements kept by sanitizeHTML, with the attributes each may carry. Anything else is unwrapped
+// (its children kept) unless it is in droppedElements.
+var allowedElements = map[atom.Atom][]string{
+	atom.P: nil, atom.Br: nil, atom.Hr: nil, atom.Div: nil, atom.Span: nil,
+	atom.H1: nil, atom.H2: nil, atom.H3: nil, atom.H4: nil, atom.H5: nil, atom.H6: nil,
+	atom.Ul: nil, atom.Ol: {"start"}, atom.Li: nil, atom.Dl: nil, atom.Dt: nil, atom.Dd: nil,
+	atom.A: {"href", "title"}, atom.Img: {"src", "alt", "title", "width", "height"},
+	atom.Strong: nil, atom.B: nil, atom.Em: nil, atom.I: nil, atom.U: nil, atom.S: nil,
+	atom.Sub: nil, atom.Sup: nil, atom.Kbd: nil, atom.Code: {"class"}, atom.Pre: nil,
+	atom.Blockquote: nil, atom.Figure: nil, atom.Figcaption: nil,
+	atom.Table: nil, atom.Thead: nil, atom.Tbody: nil, atom.Tfoot: nil, atom.Tr: nil,
+	atom.Th: {"colspan", "rowspan"}, atom.Td: {"colspan", "rowspan"},
+}
+
+// Elements removed together with their content
+var droppedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true, atom.Iframe: true,
+	atom.Object: true, atom.Embed: true, atom.Form: true, atom.Input: true, atom.Button: true,
+	atom.Select: true, atom.Textarea: true, atom.Svg: true, atom.Link: true, atom.Meta: true,
+}
+
+// setBody parses the article's HTML once and stores its three representations: sanitized HTML
+// for display, Markdown (structure preserved) for export and chat, and plain text for snippets
+func setBody(article *Article, rawHTML string) {
+	root, err := parseFragment(rawHTML)
+	if err != nil {
+		article.Body = strings.TrimSpace(rawHTML)
+		return
+	}
+
+	base, _ := url.Parse(article.URL)
+	sanitizeNode(root, base)
+
+	var sanitized strings.Builder
+	for child := root.FirstChild; child != nil; child = child.NextSibling {
+		html.Render(&sanitized, child)
+	}
+	article.Body = strings.TrimSpace(sanitized.String())
+	article.BodyMarkdown = renderMarkdown(root)
+	article.BodyText = renderPlainText(root)
+	article.Passages = splitPassages(root, article.Title, article.URL)
+	article.SimHash = simHash(article.BodyText)
+	extractAssets(root, article)
+}
+
+// parseFragment parses HTML as the content of a <div>, so fragments keep their structure
+func parseFragment(rawHTML string) (*html.Node, error) {
+	container := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(strings.NewReader(rawHTML), container)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		container.AppendChild(node)
+	}
+	return container, nil
+}
+
+// sanitizeNode strips everything outside the allowlist from n's subtree, resolves links and
+// images against base, and removes elements left empty
+func sanitizeNode(n *html.Node, base *url.URL) {
+	for child := n.FirstChild; child != nil; {
+		next := child.NextSibling
+		switch child.Type {
+		case html.CommentNode, html.DoctypeNode:
+			n.RemoveChild(child)
+		case html.ElementNode:
+			sanitizeNode(child, base)
+			allowed, ok := allowedElements[child.DataAtom]
+			switch {
+			case droppedElements[child.DataAtom]:
+				n.RemoveChild(child)
+			case !ok:
+				// Unknown wrapper: keep what's inside
+				for grandchild := child.FirstChild; grandchild != nil; {
+					following := grandchild.NextSibling
+					child.RemoveChild(grandchild)
+					n.InsertBefore(grandchild, child)
+					grandchild = following
+				}
+				n.RemoveChild(child)
+			default:
+				child.Attr = sanitizeAttributes(child, allowed, base)
+				if isEmptyElement(child) {
+					n.RemoveChild(child)
+				}
+			}
+		}
+		child = next
+	}
+}
+
+// sanitizeAttributes keeps the element's allowed attributes plus ids (deep-link anchors) and
+// drops URLs with schemes other than http(s) and mailto
+func sanitizeAttributes(n *html.Node, allowed []string, base *url.URL) []html.Attribute {
+	var kept []html.Attribute
+	for _, attr := range n.Attr {
+		if attr.Namespace != "" {
+			continue
+		}
+		if attr.Key == "id" {
+			kept = append(kept, attr)
+			continue
+		}
+		if !containsString(allowed, attr.Key) {
+			continue
+		}
+		if attr.Key == "href" || attr.Key == "src" {
+			resolved, ok := safeURL(attr.Val, base)
+			if !ok {
+				continue
+			}
+			attr.Val = resolved
+		}
+		kept = append(kept, attr)
+	}
+	return kept
+}
+
+func safeURL(raw string, base *url.URL) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, "#") {
+		return raw, true
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	if base != nil && base.Scheme != "" {
+		parsed = base.ResolveReference(parsed)
+	}
+	switch parsed.Scheme {
+	case "http", "https", "mailto", "":
+		return parsed.String(), true
+	}
+	return "", false
+}
+
+// isEmptyElement reports whether an element has no text or media worth keeping
+func isEmptyElement(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Br, atom.Hr, atom.Img, atom.Td, atom.Th, atom.Tr:
+		return false
+	}
+	if hasAttr(n, "id") && isHeading(n) {
+		return false
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode || strings.TrimSpace(child.Data) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func isHeading(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		return true
+	}
+	return false
+}
+
+func isBlockElement(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	switch n.DataAtom {
+	case atom.P, atom.Div, atom.Ul, atom.Ol, atom.Li, atom.Dl, atom.Dt, atom.Dd, atom.Pre,
+		atom.Blockquote, atom.Table, atom.Hr, atom.Figure, atom.Figcaption:
+		return true
+	}
+	return isHeading(n)
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	return getAttr(n, key) != ""
+}
+
+func getAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// renderMarkdown converts sanitized HTML to GitHub-flavored Markdown
+func renderMarkdown(root *html.Node) string {
+	var blocks []string
+	markdownBlocks(root, &blocks)
+	return strings.TrimSpace(strings.Join(blocks, "\n\n"))
+}
+
+// markdownBlocks appends the Markdown blocks of n's children; runs of inline content between
+// block elements become paragraphs
+func markdownBlocks(n *html.Node, blocks *[]string) {
+	var inline strings.Builder
+	flush := func() {
+		if text := strings.TrimSpace(inline.String()); text != "" {
+			*blocks = append(*blocks, text)
+		}
+		inline.Reset()
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if !isBlockElement(child) {
+			inline.WriteString(markdownInline(child))
+			continue
+		}
+		flush()
+
+		switch {
+		case isHeading(child):
+			level := int(child.Data[1] - '0')
+			*blocks = append(*blocks, strings.Repeat("#", level)+" "+strings.TrimSpace(markdownInlineChildren(child)))
+		case child.DataAtom == atom.P, child.DataAtom == atom.Dt, child.DataAtom == atom.Figcaption:
+			if text := strings.TrimSpace(markdownInlineChildren(child)); text != "" {
+				*blocks = append(*blocks, text)
+			}
+		case child.DataAtom == atom.Ul, child.DataAtom == atom.Ol:
+			*blocks = append(*blocks, markdownList(child, 0))
+		case child.DataAtom == atom.Pre:
+			language := ""
+			if code := firstChildElement(child, atom.Code); code != nil {
+				for _, class := range strings.Fields(getAttr(code, "class")) {
+					if strings.HasPrefix(class, "language-") {
+						language = strings.TrimPrefix(class, "language-")
+					}
+				}
+			}
+			*blocks = append(*blocks, "```"+language+"\n"+strings.TrimRight(textContent(child), "\n")+"\n```")
+		case child.DataAtom == atom.Blockquote:
+			var quoted []string
+			markdownBlocks(child, &quoted)
+			lines := strings.Split(strings.Join(quoted, "\n\n"), "\n")
+			for i, line := range lines {
+				lines[i] = strings.TrimRight("> "+line, " ")
+			}
+			*blocks = append(*blocks, strings.Join(lines, "\n"))
+		case child.DataAtom == atom.Table:
+			if table := markdownTable(child); table != "" {
+				*blocks = append(*blocks, table)
+			}
+		case child.DataAtom == atom.Hr:
+			*blocks = append(*blocks, "---")
+		default:
+			// div, figure, dd, li outside a list: recurse into the container
+			markdownBlocks(child, blocks)
+		}
+	}
+	flush()
+}
+
+func markdownInlineChildren(n *html.Node) string {
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(markdownInline(child))
+	}
+	return collapseInlineSpace(b.String())
+}
+
+func markdownInline(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return escapeMarkdown(collapseInlineSpace(n.Data))
+	}
+	if n.Type != html.ElementNode {
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		return "  \n"
+	case atom.Img:
+		return "![" + getAttr(n, "alt") + "](" + getAttr(n, "src") + ")"
+	case atom.Code, atom.Kbd:
+		return "`" + textContent(n) + "`"
+	}
+
+	inner := markdownInlineChildren(n)
+	if strings.TrimSpace(inner) == "" {
+		return inner
+	}
+	switch n.DataAtom {
+	case atom.A:
+		href := getAttr(n, "href")
+		if href == "" {
+			return inner
+		}
+		return "[" + strings.TrimSpace(inner) + "](" + href + ")"
+	case atom.Strong, atom.B:
+		return "**" + strings.TrimSpace(inner) + "**"
+	case atom.Em, atom.I:
+		return "_" + strings.TrimSpace(inner) + "_"
+	case atom.S:
+		return "~~" + strings.TrimSpace(inner) + "~~"
+	}
+	return inner
+}
+
+// markdownList renders a list with nested lists indented under their item
+func markdownList(list *html.Node, depth int) string {
+	var lines []string
+	number := 1
+	if start, err := strconv.Atoi(getAttr(list, "start")); err == nil {
+		number = start
+	}
+	indent := strings.Repeat("  ", depth)
+
+	for item := list.FirstChild; item != nil; item = item.NextSibling {
+		if item.Type != html.ElementNode || item.DataAtom != atom.Li {
+			continue
+		}
+		marker := "-"
+		if list.DataAtom == atom.Ol {
+			marker = strconv.Itoa(number) + "."
+			number++
+		}
+
+		var text strings.Builder
+		var nested []string
+		for child := item.FirstChild; child != nil; child = child.NextSibling {
+			if child.DataAtom == atom.Ul || child.DataAtom == atom.Ol {
+				nested = append(nested, markdownList(child, depth+1))
+			} else if isBlockElement(child) {
+				text.WriteString(" " + markdownInlineChildren(child) + " ")
+			} else {
+				text.WriteString(markdownInline(child))
+			}
+		}
+		lines = append(lines, indent+marker+" "+strings.TrimSpace(collapseInlineSpace(text.String())))
+		lines = append(lines, nested...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// markdownTable renders a GFM table; the first row is the header whether or not it uses <th>
+func markdownTable(table *html.Node) string {
+	var rows [][]string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.DataAtom != atom.Tr {
+				walk(child)
+				continue
+			}
+			var cells []string
+			for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
+					text := strings.TrimSpace(markdownInlineChildren(cell))
+					cells = append(cells, strings.ReplaceAll(text, "|", `\|`))
+				}
+			}
+			rows = append(rows, cells)
+		}
+	}
+	walk(table)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	columns := 0
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+	line := func(cells []string) string {
+		for len(cells) < columns {
+			cells = append(cells, "")
+		}
+		return "| " + strings.Join(cells, " | ") + " |"
+	}
+
+	lines := []string{line(rows[0]), "|" + strings.Repeat(" --- |", columns)}
+	for _, row := range rows[1:] {
+		lines = append(lines, line(row))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderPlainText flattens sanitized HTML to readable text: one line per block, bullets for
+// list items and " | " between table cells
+func renderPlainText(root *html.Node) string {
+	var w plainTextWriter
+	w.walk(root, -1)
+	return tidyLines(w.b.String())
+}
+
+// plainTextWriter accumulates plain text. When split is set it is offered every element first,
+// and an element it claims (returns true for) is neither written nor descended into.
+type plainTextWriter struct {
+	b     strings.Builder
+	split func(n *html.Node) bool
+}
+
+func (w *plainTextWriter) walk(n *html.Node, listDepth int) {
+	switch n.Type {
+	case html.TextNode:
+		text := collapseInlineSpace(n.Data)
+		// No leading space at the start of a line, a bullet or a cell
+		current := w.b.String()
+		if current == "" || strings.HasSuffix(current, "\n") || strings.HasSuffix(current, " ") {
+			text = strings.TrimLeft(text, " ")
+		}
+		w.b.WriteString(text)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if w.split != nil && w.split(n) {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		w.b.WriteString("\n")
+		return
+	case atom.Img:
+		return
+	case atom.Pre:
+		w.b.WriteString("\n" + strings.TrimRight(textContent(n), "\n") + "\n")
+		return
+	case atom.Li:
+		w.b.WriteString("\n" + strings.Repeat("  ", listDepth) + "• ")
+	case atom.Td, atom.Th:
+		if n.PrevSibling != nil {
+			w.b.WriteString(" | ")
+		}
+	}
+
+	depth := listDepth
+	if n.DataAtom == atom.Ul || n.DataAtom == atom.Ol {
+		depth++
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		w.walk(child, depth)
+	}
+	if isBlockElement(n) || n.DataAtom == atom.Tr {
+		w.b.WriteString("\n")
+	}
+}
+
+// tidyLines drops blank lines and trailing spaces
+func tidyLines(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimRight(line, " "); strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// splitPassages cuts an article into one passage per h2/h3 section, plus the introduction
+// before the first heading, so a search hit can link straight to the section that matched.
+// Headings without an id get a text fragment link (#:~:text=) that browsers scroll to.
+func splitPassages(root *html.Node, articleTitle, articleURL string) []Passage {
+	var passages []Passage
+	current := Passage{Title: articleTitle, URL: articleURL}
+
+	var w plainTextWriter
+	flush := func() {
+		current.Text = tidyLines(w.b.String())
+		if current.Text != "" {
+			current.Position = len(passages)
+			passages = append(passages, current)
+		}
+		w.b.Reset()
+	}
+	w.split = func(n *html.Node) bool {
+		if n.DataAtom != atom.H2 && n.DataAtom != atom.H3 {
+			return false
+		}
+		flush()
+		title := strings.TrimSpace(collapseInlineSpace(textContent(n)))
+		current = Passage{Title: title, Anchor: getAttr(n, "id"), URL: articleURL}
+		if current.Anchor != "" {
+			current.URL = articleURL + "#" + current.Anchor
+		} else if title != "" {
+			current.URL = articleURL + "#:~:text=" + textFragmentEscape(title)
+		}
+		return true
+	}
+	w.walk(root, -1)
+	flush()
+
+	return passages
+}
+
+// File types linked from articles that count as attachments rather than links
+var attachmentExtensions = map[string]bool{
+	".pdf": true, ".zip": true, ".csv": true, ".txt": true, ".json": true, ".xml": true,
+	".doc": true, ".docx": true, ".xls": true, ".xlsx": true, ".ppt": true, ".pptx": true,
+	".mp3": true, ".mp4": true, ".wav": true, ".mov": true, ".gz": true, ".tgz": true,
+}
+
+// extractAssets lists the images, attachments and links in an article's sanitized body. Links on
+// the article's own host are internal (and carry the target article's ID when there is one).
+func extractAssets(root *html.Node, article *Article) {
+	articleHost := hostOf(article.URL)
+	seen := make(map[string]bool)
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Img:
+				src := getAttr(n, "src")
+				if src != "" && !seen["img "+src] {
+					seen["img "+src] = true
+					article.Images = append(article.Images, Image{Src: src, Alt: getAttr(n, "alt")})
+				}
+			case atom.A:
+				addLink(article, n, articleHost, seen)
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(root)
+}
+
+func addLink(article *Article, a *html.Node, articleHost string, seen map[string]bool) {
+	href := getAttr(a, "href")
+	parsed, err := url.Parse(href)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		// Same-page anchors, mailto: and relative links on pages without a base URL
+		return
+	}
+	parsed.Fragment = ""
+	target := parsed.String()
+	if target == article.URL || seen["a "+target] {
+		return
+	}
+	seen["a "+target] = true
+	text := strings.TrimSpace(collapseInlineSpace(textContent(a)))
+
+	if strings.Contains(parsed.Path, "/article_attachments/") || attachmentExtensions[strings.ToLower(path.Ext(parsed.Path))] {
+		name := text
+		if name == "" {
+			name = path.Base(parsed.Path)
+		}
+		article.Attachments = append(article.Attachments, Attachment{Name: name, URL: target})
+		return
+	}
+
+	link := Link{URL: target, Text: text}
+	if parsed.Host == articleHost {
+		link.ArticleID = articleIDFromURL(target)
+		article.InternalLinks = append(article.InternalLinks, link)
+	} else {
+		article.ExternalLinks = append(article.ExternalLinks, link)
+	}
+}
+
+// AssetFetcher looks up attachment sizes and types with HEAD requests and, when a mirror directory
+// is set, keeps a local copy of every image and attachment. Results are cached per URL for the run
+// since the same screenshots and files are linked from many articles.
+type AssetFetcher struct {
+	probe     bool
+	mirrorDir string
+	maxBytes  int64
+	client    *http.Client
+	robots    *polite.RobotsPolicy
+	limiter   *polite.RateLimiter
+
+	mu   sync.Mutex
+	head map[string]assetInfo
+	// mirrored maps a URL to its path below mirrorDir, "" when the copy failed
+	mirrored map[string]string
+}
+
+type assetInfo struct {
+	size        int64
+	contentType string
+}
+
+func NewAssetFetcher(probe bool, mirrorDir string, maxBytes int64, config Config) *AssetFetcher {
+	return &AssetFetcher{
+		probe:     probe,
+		mirrorDir: mirrorDir,
+		maxBytes:  maxBytes,
+		client:    &http.Client{Timeout: config.RequestTimeout},
+		robots:    config.Robots,
+		limiter:   config.Limiter,
+		head:      make(map[string]assetInfo),
+		mirrored:  make(map[string]string),
 	}
+}
 
-	fmt.Printf("\n=== Summary ===\n")
-	fmt.Printf("Successfully fetched: %d articles\n", len(successfulArticles))
-	fmt.Printf("Failed: %d articles\n", len(errors))
+// Process fills in attachment sizes and types and mirrors the article's files. Failures are
+// logged and leave the fields empty; they never fail the article.
+func (f *AssetFetcher) Process(article *Article) {
+	if f == nil {
+		return
+	}
 
-	if len(errors) > 0 && len(errors) <= 10 {
-		fmt.Printf("\nErrors:\n")
-		for _, err := range errors {
-			fmt.Printf("- %v\n", err)
+	for i := range article.Attachments {
+		attachment := &article.Attachments[i]
+		if f.probe {
+			info := f.describe(attachment.URL)
+			attachment.Size, attachment.ContentType = info.size, info.contentType
 		}
-	} else if len(errors) > 10 {
-		fmt.Printf("\nShowing first 10 errors of %d total:\n", len(errors))
-		for _, err := range errors[:10] {
-			fmt.Printf("- %v\n", err)
+		if f.mirrorDir != "" {
+			attachment.LocalPath = f.mirror(attachment.URL)
+		}
+	}
+	if f.mirrorDir != "" {
+		for i := range article.Images {
+			article.Images[i].LocalPath = f.mirror(article.Images[i].Src)
 		}
 	}
 }
 
-func fetchSitemapURLs() ([]string, error) {
-	// Use optimized HTTP client with connection pooling
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			MaxIdleConnsPerHost: 10,
-			IdleConnTimeout:     90 * time.Second,
-		},
+func (f *AssetFetcher) describe(assetURL string) assetInfo {
+	f.mu.Lock()
+	info, ok := f.head[assetURL]
+	f.mu.Unlock()
+	if ok {
+		return info
 	}
 
-	sitemapURL := getEnv("SITEMAP_URL", "https://support.talkdesk.com/hc/sitemap.xml")
-	resp, err := client.Get(sitemapURL)
+	resp, err := f.request("HEAD", assetURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch sitemap from %s: %v", sitemapURL, err)
+		fmt.Printf("⚠ HEAD %s failed: %v\n", assetURL, err)
+	} else {
+		resp.Body.Close()
+		info = assetInfo{size: resp.ContentLength, contentType: resp.Header.Get("Content-Type")}
+		if info.size < 0 {
+			info.size = 0
+		}
+		if mediaType, _, err := mime.ParseMediaType(info.contentType); err == nil {
+			info.contentType = mediaType
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("sitemap returned HTTP %d from %s", resp.StatusCode, sitemapURL)
-	}
+	f.mu.Lock()
+	f.head[assetURL] = info
+	f.mu.Unlock()
+	return info
+}
 
-	var sitemap Sitemap
-	if err := xml.NewDecoder(resp.Body).Decode(&sitemap); err != nil {
-		return nil, fmt.Errorf("failed to parse XML sitemap from %s (got HTML?): %v", sitemapURL, err)
+// mirror downloads assetURL below the mirror directory unless a copy is already there; copies are
+// never removed, so the archive outlives files deleted upstream
+func (f *AssetFetcher) mirror(assetURL string) string {
+	f.mu.Lock()
+	localPath, ok := f.mirrored[assetURL]
+	f.mu.Unlock()
+	if ok {
+		return localPath
 	}
 
-	var urls []string
-	for _, url := range sitemap.URLs {
-		urls = append(urls, url.Loc)
+	localPath = mirrorPath(assetURL)
+	fullPath := filepath.Join(f.mirrorDir, localPath)
+	if _, err := os.Stat(fullPath); err != nil {
+		if err := f.download(assetURL, fullPath); err != nil {
+			fmt.Printf("⚠ Mirroring %s failed: %v\n", assetURL, err)
+			localPath = ""
+		}
 	}
 
-	return urls, nil
+	f.mu.Lock()
+	f.mirrored[assetURL] = localPath
+	f.mu.Unlock()
+	return localPath
 }
 
-func filterEnglishArticles(urls []string) []string {
-	var filtered []string
-	articlePattern := regexp.MustCompile(`/hc/en-us/articles/\d+-.+`)
+func (f *AssetFetcher) download(assetURL, fullPath string) error {
+	resp, err := f.request("GET", assetURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
 
-	// Filter for specific patterns that are likely to be real articles
-	excludePatterns := []*regexp.Regexp{
-		regexp.MustCompile(`/hc/en-us/articles/\d+$`), // Articles without titles
-		regexp.MustCompile(`/sections/`),              // Section pages
-		regexp.MustCompile(`/categories/`),            // Category pages
-		regexp.MustCompile(`/community/`),             // Community pages
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return err
+	}
+	tmpPath := fullPath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	written, err := io.Copy(file, io.LimitReader(resp.Body, f.maxBytes+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && written > f.maxBytes {
+		err = fmt.Errorf("larger than MIRROR_MAX_BYTES (%d)", f.maxBytes)
 	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, fullPath)
+}
 
-	for _, url := range urls {
-		if articlePattern.MatchString(url) {
-			// Check if URL should be excluded
-			shouldExclude := false
-			for _, excludePattern := range excludePatterns {
-				if excludePattern.MatchString(url) {
-					shouldExclude = true
-					break
-				}
-			}
+func (f *AssetFetcher) request(method, assetURL string) (*http.Response, error) {
+	return politeRequest(f.client, f.robots, f.limiter, method, assetURL)
+}
 
-			if !shouldExclude {
-				filtered = append(filtered, url)
-			}
-		}
+// politeRequest sends a GET or HEAD through robots.txt and the per-host rate limiter, see polite.Do.
+// Requests to a signed-in site carry its session cookies and Authorization header, so gated
+// attachments and links aren't mistaken for missing ones.
+func politeRequest(client *http.Client, robots *polite.RobotsPolicy, limiter *polite.RateLimiter, method, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequest(method, rawURL, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	return filtered
+	site := siteForURL(rawURL)
+	site.authorize(req.Header, rawURL)
+	if jar := site.cookieJar(); jar != nil {
+		signedIn := *client
+		signedIn.Jar = jar
+		client = &signedIn
+	}
+	return polite.Do(client, robots, limiter, req)
 }
 
-func fetchArticlesConcurrently(articleURLs []string, config Config) <-chan FetchResult {
-	results := make(chan FetchResult, len(articleURLs))
-	semaphore := make(chan struct{}, config.FetchConcurrency)
-	var wg sync.WaitGroup
-
-	for _, url := range articleURLs {
-		wg.Add(1)
-		go func(articleURL string) {
-			defer wg.Done()
+// mirrorPath maps a URL to host/path below the mirror directory. The path is cleaned so it can't
+// escape the directory, and a query string gets a short hash so variants don't overwrite each other.
+func mirrorPath(assetURL string) string {
+	parsed, err := url.Parse(assetURL)
+	if err != nil {
+		sum := sha256.Sum256([]byte(assetURL))
+		return filepath.Join("_", hex.EncodeToString(sum[:8]))
+	}
 
-			// Acquire semaphore for concurrency control
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
+	cleaned := strings.TrimPrefix(path.Clean("/"+parsed.Path), "/")
+	if cleaned == "" {
+		cleaned = "index"
+	}
+	if parsed.RawQuery != "" {
+		sum := sha256.Sum256([]byte(parsed.RawQuery))
+		ext := path.Ext(cleaned)
+		cleaned = strings.TrimSuffix(cleaned, ext) + "-" + hex.EncodeToString(sum[:4]) + ext
+	}
+	return filepath.Join(strings.ReplaceAll(parsed.Host, ":", "_"), filepath.FromSlash(cleaned))
+}
 
-			// Implement retry logic
-			var lastErr error
-			for attempt := 0; attempt <= config.MaxRetries; attempt++ {
-				if attempt > 0 {
-					// Exponential backoff for retries
-					backoff := time.Duration(attempt) * config.FetchDelay
-					time.Sleep(backoff)
-					fmt.Printf("Retrying %s (attempt %d/%d)\n", articleURL, attempt, config.MaxRetries)
-				}
+// articleLinks is one article's links as recorded in the crawl state
+type articleLinks struct {
+	URL      string
+	ID       string
+	Internal []Link
+	External []Link
+}
 
-				// Rate limiting between requests with randomization
-				if attempt == 0 {
-					// Random delay between 200-500ms to be respectful but fast
-					randomDelay := config.FetchDelay + time.Duration(rand.Intn(300))*time.Millisecond
-					time.Sleep(randomDelay)
-				}
+// LinkGraph is the internal link structure between the articles currently in the help center
+type LinkGraph struct {
+	OutLinks map[string][]string // article ID -> IDs of the articles it links to
+	InLinks  map[string][]string // article ID -> IDs of the articles linking to it
+	Edges    int
+}
 
-				article, err := scrapeFullArticle(articleURL, config.RequestTimeout)
-				if err == nil {
-					results <- FetchResult{
-						Article: article,
-						Error:   nil,
-						URL:     articleURL,
-						Retries: attempt,
-					}
-					return
-				}
-				lastErr = err
-			}
+// BrokenLink is a link from a live article to a page that is gone or isn't a listed article
+type BrokenLink struct {
+	SourceID  string
+	SourceURL string
+	LinkURL   string
+	LinkText  string
+	Status    int // HTTP status of the target, 0 when it wasn't requested
+	Reason    string
+}
 
-			// All retries failed
-			results <- FetchResult{
-				Article: nil,
-				Error:   lastErr,
-				URL:     articleURL,
-				Retries: config.MaxRetries,
+// buildLinkGraph connects the live articles through their internal links. Each source counts
+// once per target, self-links and links to articles outside the live set are left out.
+func buildLinkGraph(articles []articleLinks, liveIDs map[string]bool) *LinkGraph {
+	graph := &LinkGraph{OutLinks: make(map[string][]string), InLinks: make(map[string][]string)}
+	for _, article := range articles {
+		seen := make(map[string]bool)
+		for _, link := range article.Internal {
+			target := link.ArticleID
+			if target == "" || target == article.ID || !liveIDs[target] || seen[target] {
+				continue
 			}
-		}(url)
+			seen[target] = true
+			graph.OutLinks[article.ID] = append(graph.OutLinks[article.ID], target)
+			graph.InLinks[target] = append(graph.InLinks[target], article.ID)
+			graph.Edges++
+		}
 	}
-
-	// Close results channel when all goroutines complete
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
-
-	return results
+	for _, ids := range graph.InLinks {
+		sort.Strings(ids)
+	}
+	return graph
 }
 
-func scrapeFullArticle(articleURL string, timeout time.Duration) (*Article, error) {
-	c := colly.NewCollector(
-		colly.Async(true),
-	)
-	c.UserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
-	c.SetRequestTimeout(timeout)
-
-	// Limit concurrent requests per domain
-	c.Limit(&colly.LimitRule{
-		DomainGlob:  "*",
-		Parallelism: 2,
-		Delay:       100 * time.Millisecond,
-	})
-
-	// Add realistic headers
-	c.OnRequest(func(r *colly.Request) {
-		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
-		r.Headers.Set("Accept-Language", "en-US,en;q=0.5")
-		r.Headers.Set("Accept-Encoding", "gzip, deflate, br")
-		r.Headers.Set("DNT", "1")
-		r.Headers.Set("Connection", "keep-alive")
-		r.Headers.Set("Upgrade-Insecure-Requests", "1")
-	})
+// pushLinkGraph stores each article's in-links and in-degree (a ranking signal) in the index.
+// Only articles whose in-links changed, or that were re-indexed this run and so lost the fields,
+// are updated. It returns how many documents were updated.
+func pushLinkGraph(config sink.ElasticsearchConfig, state *CrawlState, articles []articleLinks, graph *LinkGraph, written map[string]bool) (int, []sink.Failure) {
+	indexer := sink.NewBulkIndexer(config)
+	pending := make(map[string]articleLinks)
+	for _, article := range articles {
+		inLinks := graph.InLinks[article.ID]
+		if !written[article.ID] && equalStrings(inLinks, state.LinkedFrom(article.URL)) {
+			continue
+		}
+		if inLinks == nil {
+			inLinks = []string{}
+		}
+		update, err := json.Marshal(map[string]interface{}{
+			"doc": map[string]interface{}{"in_degree": len(inLinks), "linked_from": inLinks},
+		})
+		if err != nil {
+			continue
+		}
+		pending[article.ID] = article
+		indexer.Add(sink.BulkItem{Action: "update", ID: article.ID, URL: article.URL, Source: update})
+	}
+	indexer.Close()
 
-	var article Article
-	var scrapeErr error
+	failures := indexer.Failures()
+	failed := make(map[string]bool, len(failures))
+	for _, failure := range failures {
+		failed[failure.ID] = true
+	}
+	for id, article := range pending {
+		if !failed[id] {
+			state.SetLinkedFrom(article.URL, graph.InLinks[id])
+		}
+	}
+	return indexer.IndexedIn(config.Index), failures
+}
 
-	article.URL = articleURL
+// findBrokenLinks checks the internal links of every live article, and external ones when
+// checkExternal is set. Links to listed articles are fine without a request; links to articles
+// that went 404/410 this run are broken; anything else is checked with a HEAD request.
+func findBrokenLinks(articles []articleLinks, liveIDs, goneIDs map[string]bool, checker *LinkChecker, checkExternal bool) []BrokenLink {
+	type candidate struct {
+		article articleLinks
+		link    Link
+	}
+	var toCheck []candidate
+	var broken []BrokenLink
 
-	// Extract article ID from URL
-	re := regexp.MustCompile(`/articles/(\d+)`)
-	matches := re.FindStringSubmatch(articleURL)
-	if len(matches) >= 2 {
-		article.ID = matches[1]
-	}
-
-	// Extract title - target the actual article header, get text before metadata
-	c.OnHTML("header.article-header h3", func(e *colly.HTMLElement) {
-		if article.Title == "" {
-			// Get the full text and split by newlines to extract just the title
-			fullText := strings.TrimSpace(e.Text)
-			if fullText != "" {
-				// Split by newlines and take the first non-empty line as the title
-				lines := strings.Split(fullText, "\n")
-				for _, line := range lines {
-					line = strings.TrimSpace(line)
-					// Skip empty lines and metadata lines
-					if line != "" &&
-						!strings.Contains(line, "Published") &&
-						!strings.Contains(line, "Last Updated") &&
-						!strings.Contains(line, "•") &&
-						line != "How can we help?" &&
-						line != "Knowledge Base" {
-						article.Title = line
-						break
-					}
-				}
+	for _, article := range articles {
+		for _, link := range article.Internal {
+			switch {
+			case link.ArticleID != "" && liveIDs[link.ArticleID]:
+			case link.ArticleID != "" && goneIDs[link.ArticleID]:
+				broken = append(broken, BrokenLink{SourceID: article.ID, SourceURL: article.URL, LinkURL: link.URL, LinkText: link.Text, Reason: "article removed upstream (404/410)"})
+			default:
+				toCheck = append(toCheck, candidate{article, link})
 			}
 		}
-	})
-
-	// Fallback title extraction if header method fails
-	c.OnHTML("h1, .article-title, [data-testid='article-title']", func(e *colly.HTMLElement) {
-		if article.Title == "" {
-			style := e.Attr("style")
-			if !strings.Contains(style, "display: none") {
-				title := strings.TrimSpace(e.Text)
-				if title != "" && title != "How can we help?" && title != "Knowledge Base" {
-					article.Title = title
-				}
+		if checkExternal {
+			for _, link := range article.External {
+				toCheck = append(toCheck, candidate{article, link})
 			}
 		}
-	})
+	}
 
-	// Extract the main article content with full HTML - try multiple selectors
-	c.OnHTML(".article-body", func(e *colly.HTMLElement) {
-		if article.Body == "" {
-			if html, err := e.DOM.Html(); err == nil {
-				article.Body = cleanHTML(html)
-			}
-		}
-	})
+	urls := make([]string, 0, len(toCheck))
+	for _, c := range toCheck {
+		urls = append(urls, c.link.URL)
+	}
+	statuses := checker.CheckAll(urls)
 
-	// Fallback body selectors
-	c.OnHTML(".article-content, [data-testid='article-body'], .article__body, .article-body-container", func(e *colly.HTMLElement) {
-		if article.Body == "" {
-			if html, err := e.DOM.Html(); err == nil {
-				article.Body = cleanHTML(html)
-			}
+	for _, c := range toCheck {
+		result := statuses[c.link.URL]
+		entry := BrokenLink{SourceID: c.article.ID, SourceURL: c.article.URL, LinkURL: c.link.URL, LinkText: c.link.Text, Status: result.status}
+		var blocked *polite.RobotsBlockedError
+		switch {
+		case errors.As(result.err, &blocked):
+			// Not ours to check
+			continue
+		case result.err != nil:
+			entry.Reason = fmt.Sprintf("unreachable: %v", result.err)
+		case result.status >= 400:
+			entry.Reason = fmt.Sprintf("HTTP %d", result.status)
+		case c.link.ArticleID != "":
+			entry.Reason = "article not in sitemap"
+		default:
+			continue
 		}
-	})
+		broken = append(broken, entry)
+	}
 
-	// Extract metadata if available
-	c.OnHTML("time[datetime], .article-created-at, .article-updated-at", func(e *colly.HTMLElement) {
-		datetime := e.Attr("datetime")
-		if datetime != "" {
-			if article.CreatedAt == "" {
-				article.CreatedAt = datetime
-			}
-			article.UpdatedAt = datetime
+	sort.Slice(broken, func(i, j int) bool {
+		if broken[i].SourceID != broken[j].SourceID {
+			return broken[i].SourceID < broken[j].SourceID
 		}
+		return broken[i].LinkURL < broken[j].LinkURL
 	})
+	return broken
+}
 
-	c.OnError(func(r *colly.Response, err error) {
-		scrapeErr = err
-	})
-
-	// Visit the URL
-	if err := c.Visit(articleURL); err != nil {
-		return nil, fmt.Errorf("error visiting page: %v", err)
+// writeBrokenLinkReport writes the broken links as CSV, replacing the previous report
+func writeBrokenLinkReport(reportPath string, broken []BrokenLink) error {
+	tmpPath := reportPath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
 	}
 
-	// Wait for async operations to complete
-	c.Wait()
-
-	if scrapeErr != nil {
-		return nil, fmt.Errorf("scraping error: %v", scrapeErr)
+	w := csv.NewWriter(file)
+	w.Write([]string{"source_article_id", "source_url", "link_url", "link_text", "status", "reason"})
+	for _, link := range broken {
+		status := ""
+		if link.Status != 0 {
+			status = strconv.Itoa(link.Status)
+		}
+		w.Write([]string{link.SourceID, link.SourceURL, link.LinkURL, link.LinkText, status, link.Reason})
 	}
-
-	if article.Title == "" || article.Title == "How can we help?" || article.Title == "Knowledge Base" {
-		return nil, fmt.Errorf("no meaningful title found on page")
+	w.Flush()
+	if err := w.Error(); err != nil {
+		file.Close()
+		return err
 	}
-
-	if article.Body == "" {
-		return nil, fmt.Errorf("no content found on page")
+	if err := file.Close(); err != nil {
+		return err
 	}
+	return os.Rename(tmpPath, reportPath)
+}
 
-	// Set timestamps if not found
-	if article.CreatedAt == "" {
-		article.CreatedAt = time.Now().UTC().Format(time.RFC3339)
-	}
-	if article.UpdatedAt == "" {
-		article.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
-	}
+// LinkChecker requests link targets concurrently through robots.txt and the rate limiter
+type LinkChecker struct {
+	client      *http.Client
+	robots      *polite.RobotsPolicy
+	limiter     *polite.RateLimiter
+	concurrency int
+}
 
-	return &article, nil
+type linkCheckResult struct {
+	status int
+	err    error
 }
 
-func createElasticsearchIndex(config ElasticsearchConfig) error {
-	if !config.Enabled {
-		return nil
+func NewLinkChecker(config Config) *LinkChecker {
+	return &LinkChecker{
+		// Don't follow redirects off to login pages and the like; a redirect isn't broken
+		client: &http.Client{
+			Timeout:       config.RequestTimeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		robots:      config.Robots,
+		limiter:     config.Limiter,
+		concurrency: config.FetchConcurrency,
 	}
+}
 
-	// Check if index exists first
-	checkURL := fmt.Sprintf("%s/%s", config.URL, config.Index)
-	req, err := http.NewRequest("HEAD", checkURL, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create HEAD request: %v", err)
-	}
+// CheckAll returns the status of every distinct URL. Servers that reject HEAD are retried with GET.
+func (c *LinkChecker) CheckAll(urls []string) map[string]linkCheckResult {
+	results := make(map[string]linkCheckResult)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, c.concurrency)
+
+	for _, u := range urls {
+		mu.Lock()
+		_, queued := results[u]
+		results[u] = linkCheckResult{}
+		mu.Unlock()
+		if queued {
+			continue
+		}
+
+		wg.Add(1)
+		go func(linkURL string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
 
-	if config.Username != "" && config.Password != "" {
-		req.SetBasicAuth(config.Username, config.Password)
+			result := c.check("HEAD", linkURL)
+			if result.status == http.StatusMethodNotAllowed || result.status == http.StatusNotImplemented {
+				result = c.check("GET", linkURL)
+			}
+			mu.Lock()
+			results[linkURL] = result
+			mu.Unlock()
+		}(u)
 	}
+	wg.Wait()
+	return results
+}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+func (c *LinkChecker) check(method, linkURL string) linkCheckResult {
+	resp, err := politeRequest(c.client, c.robots, c.limiter, method, linkURL)
+	var statusErr *polite.HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return linkCheckResult{status: statusErr.StatusCode}
+	}
 	if err != nil {
-		return fmt.Errorf("failed to check index existence: %v", err)
+		return linkCheckResult{err: err}
 	}
 	resp.Body.Close()
+	return linkCheckResult{status: resp.StatusCode}
+}
 
-	if resp.StatusCode == 200 {
-		fmt.Printf("📋 Elasticsearch index '%s' already exists\n", config.Index)
-		return nil
-	}
+// articleFingerprint is the simhash of an article as kept in the crawl state
+type articleFingerprint struct {
+	URL     string
+	ID      string
+	SimHash uint64
+}
 
-	// Create index with mapping
-	indexMapping := `{
-		"mappings": {
-			"properties": {
-				"id": {"type": "keyword"},
-				"title": {"type": "text", "analyzer": "standard"},
-				"body": {"type": "text", "analyzer": "standard"},
-				"url": {"type": "keyword"},
-				"created_at": {"type": "date"},
-				"updated_at": {"type": "date"},
-				"indexed_at": {"type": "date"}
-			}
+// findNearDuplicates maps every near-duplicate article to the ID of its canonical article,
+// whose fingerprint differs from its own in at most maxDistance bits. Canonicals are picked in
+// ID order: the lowest ID is canonical, normally the original the others were copied from, and
+// claims every article close to it; the lowest ID left unclaimed starts the next group. Copies
+// are never chained, so an article is only attached to a canonical it is close to itself.
+//
+// Comparing every pair would be quadratic, so the 64 bits are cut into maxDistance+1 bands:
+// two fingerprints within maxDistance bits of each other are identical in at least one band,
+// and only articles sharing a band are compared.
+func findNearDuplicates(articles []articleFingerprint, maxDistance int) map[string]string {
+	neighbours := make(map[string][]string)
+	bands := maxDistance + 1
+	width := 64 / bands
+	for band := 0; band < bands; band++ {
+		shift := band * width
+		mask := uint64(1)<<width - 1
+		if band == bands-1 {
+			mask = ^uint64(0) >> shift
 		}
-	}`
-
-	createURL := fmt.Sprintf("%s/%s", config.URL, config.Index)
-	req, err = http.NewRequest("PUT", createURL, strings.NewReader(indexMapping))
-	if err != nil {
-		return fmt.Errorf("failed to create PUT request: %v", err)
-	}
 
-	req.Header.Set("Content-Type", "application/json")
-	if config.Username != "" && config.Password != "" {
-		req.SetBasicAuth(config.Username, config.Password)
+		buckets := make(map[uint64][]articleFingerprint)
+		for _, article := range articles {
+			key := article.SimHash >> shift & mask
+			buckets[key] = append(buckets[key], article)
+		}
+		for _, bucket := range buckets {
+			for i := 0; i < len(bucket); i++ {
+				for j := i + 1; j < len(bucket); j++ {
+					a, b := bucket[i].ID, bucket[j].ID
+					// Pairs sharing several bands are compared once per band, but listed once
+					if bits.OnesCount64(bucket[i].SimHash^bucket[j].SimHash) > maxDistance || slices.Contains(neighbours[a], b) {
+						continue
+					}
+					neighbours[a] = append(neighbours[a], b)
+					neighbours[b] = append(neighbours[b], a)
+				}
+			}
+		}
 	}
 
-	resp, err = client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to create index: %v", err)
+	ids := make([]string, 0, len(neighbours))
+	for id := range neighbours {
+		ids = append(ids, id)
 	}
-	defer resp.Body.Close()
+	slices.SortFunc(ids, func(a, b string) int {
+		if lessArticleID(a, b) {
+			return -1
+		}
+		if lessArticleID(b, a) {
+			return 1
+		}
+		return 0
+	})
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("failed to create index, status: %d", resp.StatusCode)
+	duplicates := make(map[string]string)
+	canonical := make(map[string]bool)
+	for _, id := range ids {
+		if duplicates[id] != "" {
+			continue
+		}
+		canonical[id] = true
+		for _, neighbour := range neighbours[id] {
+			if duplicates[neighbour] == "" && !canonical[neighbour] {
+				duplicates[neighbour] = id
+			}
+		}
 	}
-
-	fmt.Printf("✅ Created Elasticsearch index '%s'\n", config.Index)
-	return nil
+	return duplicates
 }
 
-func createElasticsearchProcessor(config ElasticsearchConfig) ProcessorFunc {
-	return func(article *Article) error {
-		if !config.Enabled {
-			return nil
+// pushDuplicates points near-duplicate articles at their canonical article through cluster_id
+// (what search collapses on) and duplicate_of. Like pushLinkGraph it only updates articles whose
+// canonical changed or that were re-indexed this run, and returns how many it updated.
+func pushDuplicates(config sink.ElasticsearchConfig, state *CrawlState, articles []articleFingerprint, duplicates map[string]string, written map[string]bool) (int, []sink.Failure) {
+	indexer := sink.NewBulkIndexer(config)
+	pending := make(map[string]articleFingerprint)
+	for _, article := range articles {
+		canonical := duplicates[article.ID]
+		// Re-indexed documents start out as their own cluster, so only copies need re-pointing
+		if canonical == state.DuplicateOf(article.URL) && (!written[article.ID] || canonical == "") {
+			continue
 		}
-
-		// Transform article data for Elasticsearch
-		doc := map[string]interface{}{
-			"id":         article.ID,
-			"title":      article.Title,
-			"body":       article.Body,
-			"url":        article.URL,
-			"created_at": article.CreatedAt,
-			"updated_at": article.UpdatedAt,
-			"indexed_at": time.Now().UTC().Format(time.RFC3339),
+		// An article that stopped being a copy goes back to its own cluster
+		doc := map[string]interface{}{"cluster_id": article.ID, "duplicate_of": nil}
+		if canonical != "" {
+			doc = map[string]interface{}{"cluster_id": canonical, "duplicate_of": canonical}
 		}
-
-		// Convert to JSON
-		jsonData, err := json.Marshal(doc)
+		update, err := json.Marshal(map[string]interface{}{"doc": doc})
 		if err != nil {
-			return fmt.Errorf("failed to marshal document: %v", err)
+			continue
 		}
+		pending[article.ID] = article
+		indexer.Add(sink.BulkItem{Action: "update", ID: article.ID, URL: article.URL, Source: update})
+	}
+	indexer.Close()
 
-		// Create Elasticsearch request
-		var esURL string
-		if article.ID != "" {
-			esURL = fmt.Sprintf("%s/%s/_doc/%s", config.URL, config.Index, article.ID)
-		} else {
-			// Use a hash of the URL as ID if no article ID available
-			esURL = fmt.Sprintf("%s/%s/_doc", config.URL, config.Index)
+	failures := indexer.Failures()
+	failed := make(map[string]bool, len(failures))
+	for _, failure := range failures {
+		failed[failure.ID] = true
+	}
+	for id, article := range pending {
+		if !failed[id] {
+			state.SetDuplicateOf(article.URL, duplicates[id])
 		}
+	}
+	return indexer.IndexedIn(config.Index), failures
+}
 
-		req, err := http.NewRequest("PUT", esURL, strings.NewReader(string(jsonData)))
-		if err != nil {
-			return fmt.Errorf("failed to create ES request: %v", err)
-		}
+// lessArticleID orders numeric article IDs by value, so "999" comes before "1000"
+func lessArticleID(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
 
-		req.Header.Set("Content-Type", "application/json")
-		if config.Username != "" && config.Password != "" {
-			req.SetBasicAuth(config.Username, config.Password)
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
 		}
+	}
+	return true
+}
 
-		// Send to Elasticsearch with timeout
-		client := &http.Client{Timeout: 10 * time.Second}
-		resp, err := client.Do(req)
-		if err != nil {
-			return fmt.Errorf("failed to index to ES: %v", err)
-		}
-		defer resp.Body.Close()
+// textFragmentEscape percent-encodes text for a #:~:text= directive, where "," and "-" are syntax
+func textFragmentEscape(text string) string {
+	escaped := strings.ReplaceAll(url.QueryEscape(text), "+", "%20")
+	return strings.ReplaceAll(escaped, "-", "%2D")
+}
 
-		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			return fmt.Errorf("ES indexing failed with status %d", resp.StatusCode)
+func firstChildElement(n *html.Node, a atom.Atom) *html.Node {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.DataAtom == a {
+			return child
 		}
-
-		fmt.Printf("📋 Indexed to Elasticsearch: %s\n", article.Title)
-		return nil
 	}
+	return nil
 }
 
-func cleanHTML(html string) string {
-	// Remove excessive div nesting and empty elements
-	html = strings.ReplaceAll(html, "<div></div>", "")
-	html = strings.ReplaceAll(html, "<div> </div>", "")
-	html = strings.ReplaceAll(html, "<p></p>", "")
-	html = strings.ReplaceAll(html, "<p> </p>", "")
+// textContent is the raw text below n, whitespace preserved (for code)
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(textContent(child))
+	}
+	return b.String()
+}
 
-	// Remove empty spans and other elements
-	html = strings.ReplaceAll(html, "<span></span>", "")
-	html = strings.ReplaceAll(html, "<span> </span>", "")
+var inlineSpacePattern = regexp.MustCompile(`\s+`)
 
-	// Clean up multiple consecutive line breaks and spaces
-	for strings.Contains(html, "\n\n\n") {
-		html = strings.ReplaceAll(html, "\n\n\n", "\n\n")
+// collapseInlineSpace turns any whitespace run into one space, keeping Markdown hard breaks
+func collapseInlineSpace(text string) string {
+	parts := strings.Split(text, "  \n")
+	for i, part := range parts {
+		parts[i] = inlineSpacePattern.ReplaceAllString(part, " ")
 	}
+	return strings.Join(parts, "  \n")
+}
 
-	for strings.Contains(html, "  ") {
-		html = strings.ReplaceAll(html, "  ", " ")
-	}
+var markdownSpecialPattern = regexp.MustCompile("([\\\\`*_\\[\\]])")
 
-	return strings.TrimSpace(html)
+func escapeMarkdown(text string) string {
+	return markdownSpecialPattern.ReplaceAllString(text, `\$1`)
 }
 
 // Helper functions for environment variables
@@ -562,10 +5573,27 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if intVal, err := strconv.Atoi(value); err == nil {
+			return intVal
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return defaultValue
+}
+
 func getEnvBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		return value == "true" || value == "1"
 	}
 	return defaultValue
 }
-
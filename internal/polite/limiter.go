@@ -0,0 +1,190 @@
+package polite
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket per host shared by all fetch workers. A host's rate is halved
+// on 429/503, Retry-After pauses it entirely, and successes creep it back up to its ceiling.
+type RateLimiter struct {
+	rate          float64 // requests per second per host when the server is happy
+	minRate       float64
+	maxRetryAfter time.Duration
+	robots        *RobotsPolicy
+	hostRates     map[string]float64 // per-host ceilings replacing rate, see SetRate
+
+	mu    sync.Mutex
+	hosts map[string]*hostBucket
+}
+
+type hostBucket struct {
+	rate        float64
+	maxRate     float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	successes   int
+	throttled   int
+}
+
+func NewRateLimiter(rate, minRate float64, maxRetryAfter time.Duration, robots *RobotsPolicy) *RateLimiter {
+	if rate <= 0 {
+		rate = 5
+	}
+	if minRate <= 0 || minRate > rate {
+		minRate = rate / 10
+	}
+
+	fmt.Printf("⏱  Rate limit: up to %.2f req/s per host (min %.2f req/s when throttled)\n", rate, minRate)
+
+	return &RateLimiter{
+		rate:          rate,
+		minRate:       minRate,
+		maxRetryAfter: maxRetryAfter,
+		robots:        robots,
+		hostRates:     make(map[string]float64),
+		hosts:         make(map[string]*hostBucket),
+	}
+}
+
+// SetRate sets the ceiling of one host, for sites with a rate of their own. robots.txt
+// Crawl-delay still caps it.
+func (l *RateLimiter) SetRate(host string, rate float64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hostRates[host] = rate
+	fmt.Printf("⏱  Rate limit for %s: up to %.2f req/s\n", host, rate)
+}
+
+// Wait blocks until rawURL's host has a token available and is not paused by Retry-After
+func (l *RateLimiter) Wait(rawURL string) {
+	if l == nil {
+		return
+	}
+
+	// robots.txt may have to be fetched for the Crawl-delay, which must not happen under l.mu
+	host, crawlDelay := hostOf(rawURL), l.robots.CrawlDelay(rawURL)
+	for {
+		l.mu.Lock()
+		b := l.bucket(host, crawlDelay)
+		now := time.Now()
+
+		var wait time.Duration
+		if now.Before(b.pausedUntil) {
+			wait = b.pausedUntil.Sub(now)
+		} else {
+			// Refill, allowing a burst of at most one request
+			b.tokens = math.Min(1, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+			b.last = now
+			if b.tokens >= 1 {
+				b.tokens--
+				l.mu.Unlock()
+				return
+			}
+			wait = time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		}
+		l.mu.Unlock()
+
+		time.Sleep(wait)
+	}
+}
+
+// Report adjusts the host's rate after a request: 429/503 halves it and honors Retry-After,
+// a run of successes raises it again by a tenth of the ceiling
+func (l *RateLimiter) Report(rawURL string, err error) {
+	if l == nil {
+		return
+	}
+
+	host, crawlDelay := hostOf(rawURL), l.robots.CrawlDelay(rawURL)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.bucket(host, crawlDelay)
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) && IsThrottled(err) {
+		b.throttled++
+		b.successes = 0
+		b.rate = math.Max(math.Min(l.minRate, b.maxRate), b.rate/2)
+		b.tokens = 0
+
+		retryAfter := statusErr.RetryAfter
+		if retryAfter > l.maxRetryAfter {
+			retryAfter = l.maxRetryAfter
+		}
+		if retryAfter > 0 {
+			if until := time.Now().Add(retryAfter); until.After(b.pausedUntil) {
+				b.pausedUntil = until
+			}
+			fmt.Printf("🐢 HTTP %d from %s, pausing %v (Retry-After) and slowing to %.2f req/s\n", statusErr.StatusCode, host, retryAfter, b.rate)
+		} else {
+			fmt.Printf("🐢 HTTP %d from %s, slowing to %.2f req/s\n", statusErr.StatusCode, host, b.rate)
+		}
+		return
+	}
+
+	// A 304 is as good a sign of a healthy server as a 200
+	if (err != nil && !errors.Is(err, ErrNotModified)) || b.rate >= b.maxRate {
+		return
+	}
+
+	b.successes++
+	if b.successes >= 10 {
+		b.successes = 0
+		b.rate = math.Min(b.maxRate, b.rate+b.maxRate/10)
+		fmt.Printf("🐇 %s is keeping up, speeding up to %.2f req/s\n", host, b.rate)
+	}
+}
+
+// Rates describes the current effective rate of every host, for the crawl log
+func (l *RateLimiter) Rates() string {
+	if l == nil {
+		return ""
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	hosts := make([]string, 0, len(l.hosts))
+	for host := range l.hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	var parts []string
+	for _, host := range hosts {
+		b := l.hosts[host]
+		parts = append(parts, fmt.Sprintf("%s %.2f/%.2f req/s (%d throttled)", host, b.rate, b.maxRate, b.throttled))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// bucket returns the host's bucket, creating it at full speed capped by the host's robots.txt
+// Crawl-delay; callers hold l.mu
+func (l *RateLimiter) bucket(host string, crawlDelay time.Duration) *hostBucket {
+	if b, ok := l.hosts[host]; ok {
+		return b
+	}
+
+	maxRate := l.rate
+	if rate, ok := l.hostRates[host]; ok {
+		maxRate = rate
+	}
+	if crawlDelay > 0 {
+		maxRate = math.Min(maxRate, 1/crawlDelay.Seconds())
+	}
+
+	b := &hostBucket{rate: maxRate, maxRate: maxRate, tokens: 1, last: time.Now()}
+	l.hosts[host] = b
+	return b
+}
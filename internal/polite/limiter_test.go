@@ -0,0 +1,234 @@
+package polite
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const limitedURL = "https://help.example.com/hc/en-us/articles/1"
+
+func throttled(status int, retryAfter time.Duration) error {
+	return &HTTPStatusError{URL: limitedURL, StatusCode: status, Message: http.StatusText(status), RetryAfter: retryAfter}
+}
+
+func TestRateLimiterReportThrottling(t *testing.T) {
+	tests := []struct {
+		name     string
+		reports  []error
+		wantRate float64
+	}{
+		{"429 halves the rate", []error{throttled(429, 0)}, 4},
+		{"503 halves the rate", []error{throttled(503, 0)}, 4},
+		{"halving repeats", []error{throttled(429, 0), throttled(503, 0)}, 2},
+		{"never below the minimum", []error{throttled(429, 0), throttled(429, 0), throttled(429, 0), throttled(429, 0)}, 1},
+		{"500 is not throttling", []error{throttled(500, 0)}, 8},
+		{"404 is not throttling", []error{throttled(404, 0)}, 8},
+		{"network errors are not throttling", []error{errors.New("connection reset")}, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewRateLimiter(8, 1, time.Minute, nil)
+			for _, err := range tt.reports {
+				limiter.Report(limitedURL, err)
+			}
+			if b := limiter.hosts["help.example.com"]; b.rate != tt.wantRate {
+				t.Errorf("rate = %v, want %v", b.rate, tt.wantRate)
+			}
+		})
+	}
+}
+
+func TestRateLimiterRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter []time.Duration
+		wantPause  time.Duration
+	}{
+		{"no Retry-After, no pause", []time.Duration{0}, 0},
+		{"Retry-After pauses the host", []time.Duration{20 * time.Second}, 20 * time.Second},
+		{"capped at the maximum", []time.Duration{time.Hour}, time.Minute},
+		{"a shorter Retry-After doesn't cut the pause short", []time.Duration{40 * time.Second, 5 * time.Second}, 40 * time.Second},
+		{"a longer one extends it", []time.Duration{5 * time.Second, 40 * time.Second}, 40 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewRateLimiter(8, 1, time.Minute, nil)
+			start := time.Now()
+			for _, retryAfter := range tt.retryAfter {
+				limiter.Report(limitedURL, throttled(429, retryAfter))
+			}
+			b := limiter.hosts["help.example.com"]
+			pause := time.Duration(0)
+			if !b.pausedUntil.IsZero() {
+				pause = b.pausedUntil.Sub(start)
+			}
+			if pause < tt.wantPause || pause > tt.wantPause+time.Second {
+				t.Errorf("paused for %v, want %v", pause, tt.wantPause)
+			}
+			if b.tokens != 0 {
+				t.Errorf("tokens = %v after throttling, want 0", b.tokens)
+			}
+		})
+	}
+}
+
+func TestRateLimiterRecovery(t *testing.T) {
+	limiter := NewRateLimiter(10, 1, time.Minute, nil)
+	limiter.Report(limitedURL, throttled(429, 0))
+	limiter.Report(limitedURL, throttled(429, 0))
+	b := limiter.hosts["help.example.com"]
+	if b.rate != 2.5 {
+		t.Fatalf("rate = %v after two 429s, want 2.5", b.rate)
+	}
+
+	// Nine successes are not enough; a 304 counts as one, other failures don't count
+	for i := 0; i < 8; i++ {
+		limiter.Report(limitedURL, nil)
+	}
+	limiter.Report(limitedURL, fmt.Errorf("fetching: %w", ErrNotModified))
+	limiter.Report(limitedURL, throttled(404, 0))
+	if b.rate != 2.5 {
+		t.Fatalf("rate = %v after nine successes, want 2.5", b.rate)
+	}
+	// The tenth raises the rate by a tenth of the ceiling
+	limiter.Report(limitedURL, nil)
+	if b.rate != 3.5 {
+		t.Fatalf("rate = %v after ten successes, want 3.5", b.rate)
+	}
+
+	// A 429 starts the count over
+	for i := 0; i < 9; i++ {
+		limiter.Report(limitedURL, nil)
+	}
+	limiter.Report(limitedURL, throttled(503, 0))
+	for i := 0; i < 9; i++ {
+		limiter.Report(limitedURL, nil)
+	}
+	if b.rate != 1.75 {
+		t.Fatalf("rate = %v, want 1.75: the 503 halved it and the successes after it are only nine", b.rate)
+	}
+
+	// Recovery stops at the ceiling
+	for i := 0; i < 200; i++ {
+		limiter.Report(limitedURL, nil)
+	}
+	if b.rate != 10 {
+		t.Errorf("rate = %v after a long run of successes, want the ceiling 10", b.rate)
+	}
+}
+
+func TestRateLimiterCeiling(t *testing.T) {
+	slow := robotsServer(t, http.StatusOK, "User-agent: *\nCrawl-delay: 2\n")
+	fast := robotsServer(t, http.StatusOK, "User-agent: *\nCrawl-delay: 0.01\n")
+	open := robotsServer(t, http.StatusNotFound, "")
+	robots := NewRobotsPolicy(true, "release-crawler", time.Minute)
+	limiter := NewRateLimiter(5, 0.1, time.Minute, robots)
+	limiter.SetRate(hostOf(fast.URL), 20)
+	limiter.SetRate(hostOf(slow.URL), 3)
+
+	tests := []struct {
+		name    string
+		url     string
+		wantMax float64
+	}{
+		{"shared rate", open.URL + "/", 5},
+		// Crawl-delay: 0.01 allows 100 req/s, above the site's own rate
+		{"site rate", fast.URL + "/", 20},
+		// Crawl-delay: 2 means at most one request every two seconds, below both rates
+		{"Crawl-delay caps the site rate", slow.URL + "/", 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter.Report(tt.url, nil)
+			b := limiter.hosts[hostOf(tt.url)]
+			if b.maxRate != tt.wantMax || b.rate != tt.wantMax {
+				t.Errorf("rate %v, ceiling %v, want both %v", b.rate, b.maxRate, tt.wantMax)
+			}
+		})
+	}
+
+	// Throttling never goes below the minimum, nor above a ceiling lower than it
+	limiter = NewRateLimiter(5, 1, time.Minute, robots)
+	for i := 0; i < 5; i++ {
+		limiter.Report(slow.URL+"/", throttled(429, 0))
+	}
+	if b := limiter.hosts[hostOf(slow.URL)]; b.rate != 0.5 {
+		t.Errorf("rate = %v under a 0.5 req/s Crawl-delay ceiling, want 0.5", b.rate)
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	limiter := NewRateLimiter(20, 1, time.Minute, nil)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		limiter.Wait(limitedURL)
+	}
+	// One request right away, then one per 50ms
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond || elapsed > time.Second {
+		t.Errorf("three requests at 20 req/s took %v, want about 100ms", elapsed)
+	}
+
+	limiter.Report(limitedURL, throttled(429, time.Second))
+	start = time.Now()
+	limiter.Wait(limitedURL)
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Errorf("Wait returned after %v while the host was paused for 1s", elapsed)
+	}
+
+	var nilLimiter *RateLimiter
+	nilLimiter.Wait(limitedURL)
+	nilLimiter.Report(limitedURL, throttled(429, time.Hour))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"empty", "", 0},
+		{"seconds", "120", 2 * time.Minute},
+		{"seconds with spaces", " 5 ", 5 * time.Second},
+		{"zero", "0", 0},
+		{"negative seconds", "-3", 0},
+		{"fraction", "1.5", 0},
+		{"garbage", "soon", 0},
+		{"HTTP date", now.Add(90 * time.Second).UTC().Format(http.TimeFormat), 90 * time.Second},
+		{"RFC 850 date", now.Add(90 * time.Second).UTC().Format(time.RFC850), 90 * time.Second},
+		{"ANSI C date", now.Add(90 * time.Second).UTC().Format(time.ANSIC), 90 * time.Second},
+		{"date in the past", now.Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseRetryAfter(tt.value)
+			// HTTP dates have whole seconds, so a date-based delay can come out up to 2s short
+			if got > tt.want || got < tt.want-2*time.Second {
+				t.Errorf("ParseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsThrottled(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{throttled(429, 0), true},
+		{throttled(503, 0), true},
+		{fmt.Errorf("fetching: %w", throttled(429, 0)), true},
+		{throttled(500, 0), false},
+		{throttled(404, 0), false},
+		{errors.New("HTTP 429"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsThrottled(tt.err); got != tt.want {
+			t.Errorf("IsThrottled(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Package polite is how the crawler programs talk to other people's servers: robots.txt rules
+// and Crawl-delay, an adaptive per-host rate limit, and one user agent for all of it.
+package polite
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPStatusError carries the status of a failed page fetch so callers can tell "gone" from "flaky"
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("HTTP %d from %s: %s", e.StatusCode, e.URL, e.Message)
+}
+
+// ErrNotModified is what a conditional request returns when the server answered 304
+var ErrNotModified = errors.New("not modified")
+
+// Do sends req through robots.txt and the per-host rate limiter, as the policy's user agent. Any
+// status other than 2xx comes back as an *HTTPStatusError, a disallowed URL as a
+// *RobotsBlockedError without a request.
+func Do(client *http.Client, robots *RobotsPolicy, limiter *RateLimiter, req *http.Request) (*http.Response, error) {
+	rawURL := req.URL.String()
+	if allowed, rule := robots.Allowed(rawURL); !allowed {
+		return nil, &RobotsBlockedError{URL: rawURL, Rule: rule}
+	}
+	req.Header.Set("User-Agent", robots.UserAgent())
+
+	limiter.Wait(rawURL)
+	resp, err := client.Do(req)
+	if err == nil && resp.StatusCode >= 300 {
+		resp.Body.Close()
+		err = &HTTPStatusError{URL: rawURL, StatusCode: resp.StatusCode, Message: resp.Status,
+			RetryAfter: ParseRetryAfter(resp.Header.Get("Retry-After"))}
+	}
+	limiter.Report(rawURL, err)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// IsThrottled reports whether the server asked us to slow down
+func IsThrottled(err error) bool {
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode == http.StatusServiceUnavailable
+}
+
+// ParseRetryAfter accepts both forms of Retry-After: delay-seconds and an HTTP date
+func ParseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Host
+}
@@ -0,0 +1,327 @@
+package polite
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RobotsPolicy fetches robots.txt once per host and decides whether a URL may be crawled
+type RobotsPolicy struct {
+	enabled       bool
+	userAgent     string
+	maxCrawlDelay time.Duration
+	client        *http.Client
+
+	mu    sync.Mutex
+	hosts map[string]*robotsRules
+}
+
+// robotsRules are the robots.txt rules that apply to our user agent on one host
+type robotsRules struct {
+	ready      chan struct{}
+	group      string // the User-agent line(s) of the group we matched, for logging
+	rules      []robotsRule
+	crawlDelay time.Duration
+	disallowed string // set when robots.txt could not be read and the host is off limits
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// RobotsBlockedError is returned for URLs robots.txt does not let us fetch
+type RobotsBlockedError struct {
+	URL  string
+	Rule string
+}
+
+func (e *RobotsBlockedError) Error() string {
+	return fmt.Sprintf("blocked by robots.txt: %s", e.Rule)
+}
+
+func NewRobotsPolicy(enabled bool, userAgent string, maxCrawlDelay time.Duration) *RobotsPolicy {
+	if enabled {
+		fmt.Printf("🤖 Respecting robots.txt as user agent %q\n", userAgent)
+	} else {
+		fmt.Println("⚠ RESPECT_ROBOTS_TXT=false, robots.txt rules and Crawl-delay are ignored")
+	}
+
+	return &RobotsPolicy{
+		enabled:       enabled,
+		userAgent:     userAgent,
+		maxCrawlDelay: maxCrawlDelay,
+		client:        &http.Client{Timeout: 15 * time.Second},
+		hosts:         make(map[string]*robotsRules),
+	}
+}
+
+// UserAgent is the user agent robots.txt rules are matched against, and the one every request
+// of the crawl should send
+func (p *RobotsPolicy) UserAgent() string {
+	if p == nil {
+		return ""
+	}
+	return p.userAgent
+}
+
+// Allowed reports whether rawURL may be fetched and, if not, the rule that blocked it
+func (p *RobotsPolicy) Allowed(rawURL string) (bool, string) {
+	if p == nil || !p.enabled {
+		return true, ""
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return true, ""
+	}
+
+	rules := p.rulesFor(u)
+	if rules.disallowed != "" {
+		return false, rules.disallowed
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	// The longest matching pattern wins; on a tie Allow beats Disallow
+	var best *robotsRule
+	for i := range rules.rules {
+		rule := &rules.rules[i]
+		if !robotsPatternMatches(rule.pattern, path) {
+			continue
+		}
+		if best == nil || len(rule.pattern) > len(best.pattern) || (len(rule.pattern) == len(best.pattern) && rule.allow) {
+			best = rule
+		}
+	}
+
+	if best == nil || best.allow {
+		return true, ""
+	}
+	return false, fmt.Sprintf("\"Disallow: %s\" (%s on %s)", best.pattern, rules.group, u.Host)
+}
+
+// CrawlDelay returns the Crawl-delay robots.txt asks for on rawURL's host
+func (p *RobotsPolicy) CrawlDelay(rawURL string) time.Duration {
+	if p == nil || !p.enabled {
+		return 0
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return 0
+	}
+	return p.rulesFor(u).crawlDelay
+}
+
+// rulesFor returns the cached rules for a host, fetching robots.txt on first use.
+// Concurrent callers for the same host wait for the single in-flight fetch.
+func (p *RobotsPolicy) rulesFor(u *url.URL) *robotsRules {
+	key := u.Scheme + "://" + u.Host
+
+	p.mu.Lock()
+	rules, exists := p.hosts[key]
+	if !exists {
+		rules = &robotsRules{ready: make(chan struct{})}
+		p.hosts[key] = rules
+	}
+	p.mu.Unlock()
+
+	if exists {
+		<-rules.ready
+		return rules
+	}
+
+	p.fetch(key, rules)
+	close(rules.ready)
+	return rules
+}
+
+func (p *RobotsPolicy) fetch(origin string, rules *robotsRules) {
+	robotsURL := origin + "/robots.txt"
+
+	var resp *http.Response
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		var req *http.Request
+		if req, err = http.NewRequest("GET", robotsURL, nil); err != nil {
+			break
+		}
+		req.Header.Set("User-Agent", p.userAgent)
+		resp, err = p.client.Do(req)
+		if err == nil && resp.StatusCode < 500 {
+			break
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+		time.Sleep(time.Second)
+	}
+
+	// Per the robots.txt spec an unreachable or erroring robots.txt means "assume everything is disallowed"
+	if err != nil {
+		rules.disallowed = fmt.Sprintf("robots.txt unreachable on %s (%v)", origin, err)
+		fmt.Printf("⚠ %s, not crawling this host\n", rules.disallowed)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		rules.disallowed = fmt.Sprintf("robots.txt returned HTTP %d on %s", resp.StatusCode, origin)
+		fmt.Printf("⚠ %s, not crawling this host\n", rules.disallowed)
+		return
+	}
+	// A missing robots.txt (4xx) means there are no restrictions
+	if resp.StatusCode >= 400 {
+		fmt.Printf("🤖 No robots.txt on %s (HTTP %d), no restrictions\n", origin, resp.StatusCode)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 512*1024))
+	if err != nil {
+		rules.disallowed = fmt.Sprintf("robots.txt unreadable on %s (%v)", origin, err)
+		return
+	}
+
+	parseRobotsTxt(string(data), p.userAgent, rules)
+
+	if rules.crawlDelay > p.maxCrawlDelay {
+		fmt.Printf("⚠ %s asks for Crawl-delay %v, capping at %v\n", origin, rules.crawlDelay, p.maxCrawlDelay)
+		rules.crawlDelay = p.maxCrawlDelay
+	}
+	fmt.Printf("🤖 Loaded robots.txt for %s: %d rules for %s, Crawl-delay %v\n", origin, len(rules.rules), rules.group, rules.crawlDelay)
+}
+
+// parseRobotsTxt keeps the rules of the group that most specifically matches userAgent,
+// falling back to the "*" group
+func parseRobotsTxt(content, userAgent string, rules *robotsRules) {
+	type group struct {
+		agents     []string
+		rules      []robotsRule
+		crawlDelay time.Duration
+	}
+
+	var groups []*group
+	var current *group
+	lastWasAgent := false
+
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// Consecutive User-agent lines share one group
+			if current == nil || !lastWasAgent {
+				current = &group{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			lastWasAgent = true
+			continue
+		case "allow", "disallow":
+			// An empty Disallow allows everything, it adds no rule
+			if current != nil && value != "" {
+				current.rules = append(current.rules, robotsRule{allow: key == "allow", pattern: value})
+			}
+		case "crawl-delay":
+			if current != nil {
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					current.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+		lastWasAgent = false
+	}
+
+	// A group counts with its most specific agent: "*" matches with length 0, a name contained in
+	// our user agent with its length
+	agent := strings.ToLower(userAgent)
+	bestLen := -1
+	var matched []*group
+	for _, g := range groups {
+		length := -1
+		for _, a := range g.agents {
+			switch {
+			case a == "*":
+				length = max(length, 0)
+			case strings.Contains(agent, a):
+				length = max(length, len(a))
+			}
+		}
+		if length < 0 {
+			continue
+		}
+		if length > bestLen {
+			bestLen = length
+			matched = []*group{g}
+		} else if length == bestLen {
+			matched = append(matched, g)
+		}
+	}
+
+	if len(matched) == 0 {
+		rules.group = "no matching group"
+		return
+	}
+
+	var agents []string
+	for _, g := range matched {
+		rules.rules = append(rules.rules, g.rules...)
+		if g.crawlDelay > rules.crawlDelay {
+			rules.crawlDelay = g.crawlDelay
+		}
+		agents = append(agents, g.agents...)
+	}
+	rules.group = "User-agent: " + strings.Join(agents, ", ")
+}
+
+// robotsPatternMatches implements robots.txt path matching: prefix match, "*" wildcards
+// and a trailing "$" anchor
+func robotsPatternMatches(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = strings.TrimSuffix(pattern, "$")
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+
+	for i, part := range parts[1:] {
+		// The last part of an anchored pattern has to sit at the very end of the path
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(path[pos:], part)
+		}
+		idx := strings.Index(path[pos:], part)
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(part)
+	}
+
+	if anchored && len(parts) == 1 {
+		return pos == len(path)
+	}
+	return true
+}
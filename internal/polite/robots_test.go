@@ -0,0 +1,257 @@
+package polite
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRobotsTxt(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		userAgent string
+		wantGroup string
+		wantRules []robotsRule
+		wantDelay time.Duration
+	}{
+		{
+			name:      "falls back to the star group",
+			content:   "User-agent: googlebot\nDisallow: /google\n\nUser-agent: *\nDisallow: /private\n",
+			userAgent: "release-crawler",
+			wantGroup: "User-agent: *",
+			wantRules: []robotsRule{{pattern: "/private"}},
+		},
+		{
+			name:      "our own group wins over the star group",
+			content:   "User-agent: *\nDisallow: /\n\nUser-agent: release-crawler\nAllow: /hc/\nCrawl-delay: 2\n",
+			userAgent: "Release-Crawler",
+			wantGroup: "User-agent: release-crawler",
+			wantRules: []robotsRule{{allow: true, pattern: "/hc/"}},
+			wantDelay: 2 * time.Second,
+		},
+		{
+			name:      "the longest matching agent wins",
+			content:   "User-agent: release\nDisallow: /a\n\nUser-agent: release-crawler\nDisallow: /b\n",
+			userAgent: "release-crawler/2.0",
+			wantGroup: "User-agent: release-crawler",
+			wantRules: []robotsRule{{pattern: "/b"}},
+		},
+		{
+			name:      "consecutive User-agent lines share a group",
+			content:   "User-agent: otherbot\nUser-agent: release-crawler\nDisallow: /shared\n\nUser-agent: *\nDisallow: /\n",
+			userAgent: "release-crawler",
+			wantGroup: "User-agent: otherbot, release-crawler",
+			wantRules: []robotsRule{{pattern: "/shared"}},
+		},
+		{
+			name:      "a group counts with its most specific agent",
+			content:   "User-agent: *\nUser-agent: release-crawler\nDisallow: /both\n\nUser-agent: crawler\nDisallow: /other\n",
+			userAgent: "release-crawler",
+			wantGroup: "User-agent: *, release-crawler",
+			wantRules: []robotsRule{{pattern: "/both"}},
+		},
+		{
+			name:      "a rule line ends the agent list",
+			content:   "User-agent: release-crawler\nDisallow: /a\nUser-agent: otherbot\nDisallow: /b\n",
+			userAgent: "release-crawler",
+			wantGroup: "User-agent: release-crawler",
+			wantRules: []robotsRule{{pattern: "/a"}},
+		},
+		{
+			name:      "equally specific groups are merged",
+			content:   "User-agent: *\nDisallow: /a\nCrawl-delay: 1\n\nUser-agent: *\nDisallow: /b\nCrawl-delay: 3\n",
+			userAgent: "release-crawler",
+			wantGroup: "User-agent: *, *",
+			wantRules: []robotsRule{{pattern: "/a"}, {pattern: "/b"}},
+			wantDelay: 3 * time.Second,
+		},
+		{
+			name:      "comments, empty Disallow and case of keys",
+			content:   "# robots\nUSER-AGENT: * # everyone\nDisallow:\nALLOW: /docs # public\ncrawl-delay: 0.5\nSitemap: https://example.com/sitemap.xml\n",
+			userAgent: "release-crawler",
+			wantGroup: "User-agent: *",
+			wantRules: []robotsRule{{allow: true, pattern: "/docs"}},
+			wantDelay: 500 * time.Millisecond,
+		},
+		{
+			name:      "invalid Crawl-delay ignored",
+			content:   "User-agent: *\nCrawl-delay: soon\nCrawl-delay: -1\n",
+			userAgent: "release-crawler",
+			wantGroup: "User-agent: *",
+		},
+		{
+			name:      "rules before any User-agent ignored",
+			content:   "Disallow: /\nUser-agent: *\nDisallow: /tmp\n",
+			userAgent: "release-crawler",
+			wantGroup: "User-agent: *",
+			wantRules: []robotsRule{{pattern: "/tmp"}},
+		},
+		{
+			name:      "no matching group",
+			content:   "User-agent: googlebot\nDisallow: /\n",
+			userAgent: "release-crawler",
+			wantGroup: "no matching group",
+		},
+		{
+			name:      "CRLF line endings",
+			content:   "User-agent: *\r\nDisallow: /private\r\n",
+			userAgent: "release-crawler",
+			wantGroup: "User-agent: *",
+			wantRules: []robotsRule{{pattern: "/private"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules robotsRules
+			parseRobotsTxt(tt.content, tt.userAgent, &rules)
+			if rules.group != tt.wantGroup {
+				t.Errorf("group = %q, want %q", rules.group, tt.wantGroup)
+			}
+			if !slices.Equal(rules.rules, tt.wantRules) {
+				t.Errorf("rules = %+v, want %+v", rules.rules, tt.wantRules)
+			}
+			if rules.crawlDelay != tt.wantDelay {
+				t.Errorf("crawl delay = %v, want %v", rules.crawlDelay, tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestRobotsPatternMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/", "/anything", true},
+		{"/hc/", "/hc/en-us/articles/1", true},
+		{"/hc/", "/hc", false},
+		{"/fish", "/fish.html", true},
+		{"/fish", "/Fish", false},
+		{"/*.pdf", "/docs/guide.pdf", true},
+		{"/*.pdf", "/docs/guide.pdf?download=1", true},
+		{"/*.pdf$", "/docs/guide.pdf", true},
+		{"/*.pdf$", "/docs/guide.pdf?download=1", false},
+		{"/*.pdf$", "/docs/guide.pdf.html", false},
+		{"/guide$", "/guide", true},
+		{"/guide$", "/guide/", false},
+		{"/$", "/", true},
+		{"/$", "/index.html", false},
+		{"/*?print=", "/hc/articles/1?print=1", true},
+		{"/*?print=", "/hc/articles/1", false},
+		{"/a*b*c", "/axxbyyc", true},
+		{"/a*b*c", "/axxcyyb", false},
+		{"/a*b$", "/axbyb", true},
+		{"/a*b$", "/axbyc", false},
+		{"/a*a$", "/a", false},
+		{"/a*a$", "/aa", true},
+		{"*", "/", true},
+		{"/**/private", "/x/private", true},
+	}
+	for _, tt := range tests {
+		if got := robotsPatternMatches(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("robotsPatternMatches(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+// robotsServer serves robots.txt with the given status and body
+func robotsServer(t *testing.T, status int, body string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRobotsPolicyAllowed(t *testing.T) {
+	const robots = `User-agent: *
+Disallow: /hc/
+Allow: /hc/en-us/articles/
+Disallow: /hc/en-us/articles/*/print
+Allow: /tie
+Disallow: /tie
+Disallow: /*.json$
+`
+	server := robotsServer(t, http.StatusOK, robots)
+	policy := NewRobotsPolicy(true, "release-crawler", time.Minute)
+
+	tests := []struct {
+		path string
+		want bool
+		rule string
+	}{
+		{"/", true, ""},
+		{"/hc/en-us/sections/1", false, "Disallow: /hc/"},
+		// The longer Allow wins over the shorter Disallow
+		{"/hc/en-us/articles/1", true, ""},
+		// ...and a longer Disallow wins over it again
+		{"/hc/en-us/articles/1/print", false, "Disallow: /hc/en-us/articles/*/print"},
+		// Allow wins a tie between equally long patterns
+		{"/tie", true, ""},
+		{"/api/articles.json", false, "Disallow: /*.json$"},
+		{"/api/articles.json?page=2", true, ""},
+	}
+	for _, tt := range tests {
+		allowed, rule := policy.Allowed(server.URL + tt.path)
+		if allowed != tt.want || !strings.Contains(rule, tt.rule) {
+			t.Errorf("Allowed(%s) = %v %q, want %v %q", tt.path, allowed, rule, tt.want, tt.rule)
+		}
+	}
+}
+
+func TestRobotsPolicyFetchStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   bool
+	}{
+		{"rules", http.StatusOK, "User-agent: *\nDisallow: /\n", false},
+		{"missing robots.txt allows everything", http.StatusNotFound, "", true},
+		{"forbidden robots.txt allows everything", http.StatusForbidden, "User-agent: *\nDisallow: /\n", true},
+		{"server error disallows everything", http.StatusServiceUnavailable, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := robotsServer(t, tt.status, tt.body)
+			policy := NewRobotsPolicy(true, "release-crawler", time.Minute)
+			if allowed, rule := policy.Allowed(server.URL + "/hc/en-us/articles/1"); allowed != tt.want {
+				t.Errorf("Allowed = %v (%s), want %v", allowed, rule, tt.want)
+			}
+		})
+	}
+
+	t.Run("unreachable robots.txt disallows everything", func(t *testing.T) {
+		server := robotsServer(t, http.StatusOK, "")
+		server.Close()
+		policy := NewRobotsPolicy(true, "release-crawler", time.Minute)
+		if allowed, _ := policy.Allowed(server.URL + "/"); allowed {
+			t.Error("allowed a host whose robots.txt could not be fetched")
+		}
+	})
+}
+
+func TestRobotsPolicyCrawlDelay(t *testing.T) {
+	server := robotsServer(t, http.StatusOK, "User-agent: *\nCrawl-delay: 120\n")
+
+	if delay := NewRobotsPolicy(true, "release-crawler", 30*time.Second).CrawlDelay(server.URL + "/"); delay != 30*time.Second {
+		t.Errorf("CrawlDelay = %v, want it capped at 30s", delay)
+	}
+	if delay := NewRobotsPolicy(false, "release-crawler", 30*time.Second).CrawlDelay(server.URL + "/"); delay != 0 {
+		t.Errorf("CrawlDelay = %v with robots.txt disabled, want 0", delay)
+	}
+	var nilPolicy *RobotsPolicy
+	if allowed, _ := nilPolicy.Allowed(server.URL + "/"); !allowed {
+		t.Error("a nil policy blocked a URL")
+	}
+}
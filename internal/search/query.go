@@ -0,0 +1,287 @@
+package search
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Query builds the body of an article search: a fuzzy, function-scored match, or an exact
+// phrase match when the query is in double quotes. Results are paged with from and size.
+func Query(query string, from, size int, filters Filters) map[string]interface{} {
+	// Detect if it's a phrase search (quoted)
+	isPhrase := strings.HasPrefix(query, "\"") && strings.HasSuffix(query, "\"")
+	if isPhrase {
+		query = strings.Trim(query, "\"")
+		return phraseQuery(query, from, size, filters)
+	}
+
+	// Use function scoring with recency boost
+	return map[string]interface{}{
+		"query": map[string]interface{}{
+			"function_score": map[string]interface{}{
+				"query": map[string]interface{}{
+					"bool": map[string]interface{}{
+						"should": []map[string]interface{}{
+							// Exact match (highest boost)
+							{
+								"multi_match": map[string]interface{}{
+									"query":  query,
+									"fields": filters.fields("title^5", "body^2"),
+									"type":   "phrase",
+									"boost":  10,
+								},
+							},
+							// Fuzzy match for typos
+							{
+								"multi_match": map[string]interface{}{
+									"query":     query,
+									"fields":    filters.fields("title^3", "body^1"),
+									"fuzziness": "AUTO",
+									"boost":     5,
+								},
+							},
+							// Prefix match for partial typing
+							{
+								"multi_match": map[string]interface{}{
+									"query":  query,
+									"fields": filters.fields("title^2", "body^1"),
+									"type":   "phrase_prefix",
+									"boost":  3,
+								},
+							},
+							// Best-matching section, returned as an inner hit for the deep link
+							passageQuery(query, false),
+						},
+						"minimum_should_match": 1,
+						"filter":               filters.clauses(),
+						"must_not":             ExcludeDeletedArticles(),
+					},
+				},
+				"boost_mode": "multiply",
+				"score_mode": "sum",
+				"functions":  rankingFunctions(),
+			},
+		},
+		"from":    from,
+		"size":    size,
+		"_source": articleSourceExcludes(),
+		"sort": []map[string]interface{}{
+			{"_score": map[string]string{"order": "desc"}},
+		},
+		"highlight": map[string]interface{}{
+			"fields": map[string]interface{}{
+				"title": map[string]interface{}{"fragment_size": 150},
+				"body":  map[string]interface{}{"fragment_size": 300},
+			},
+			"pre_tags":  []string{"<mark>"},
+			"post_tags": []string{"</mark>"},
+		},
+	}
+}
+
+// phraseQuery matches the quoted phrase exactly, newest articles first among equal scores
+func phraseQuery(query string, from, size int, filters Filters) map[string]interface{} {
+	return map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must": map[string]interface{}{
+					"multi_match": map[string]interface{}{
+						"query":  query,
+						"fields": filters.fields("title^3", "body^1"),
+						"type":   "phrase",
+					},
+				},
+				"should":   passageQuery(query, true),
+				"filter":   filters.clauses(),
+				"must_not": ExcludeDeletedArticles(),
+			},
+		},
+		"from":    from,
+		"size":    size,
+		"_source": articleSourceExcludes(),
+		"sort": []map[string]interface{}{
+			{"_score": map[string]string{"order": "desc"}},
+			{"updated_at": map[string]string{"order": "desc"}},
+		},
+		"highlight": map[string]interface{}{
+			"fields": map[string]interface{}{
+				"title": map[string]interface{}{},
+				"body":  map[string]interface{}{},
+			},
+			"pre_tags":  []string{"<mark>"},
+			"post_tags": []string{"</mark>"},
+		},
+	}
+}
+
+// passageQuery matches the query against each passage (h2/h3 section) of an article and asks for
+// the best one as an inner hit. Passage text is left out of the response, the title and link suffice.
+func passageQuery(query string, phrase bool) map[string]interface{} {
+	match := map[string]interface{}{
+		"query":  query,
+		"fields": []string{"passages.title^2", "passages.text"},
+	}
+	if phrase {
+		match["type"] = "phrase"
+	} else {
+		match["fuzziness"] = "AUTO"
+	}
+
+	return map[string]interface{}{
+		"nested": map[string]interface{}{
+			"path":            "passages",
+			"query":           map[string]interface{}{"multi_match": match},
+			"score_mode":      "max",
+			"ignore_unmapped": true,
+			"inner_hits": map[string]interface{}{
+				"size":    1,
+				"_source": []string{"passages.title", "passages.anchor", "passages.url"},
+			},
+		},
+	}
+}
+
+// articleSourceExcludes keeps the nested passages and Markdown body out of search responses
+func articleSourceExcludes() map[string]interface{} {
+	return map[string]interface{}{"excludes": []string{"passages", "body_markdown"}}
+}
+
+// rankingFunctions boosts recently updated articles and articles that many others link to.
+// Articles with no known update date get the boost of a month-old article instead of being
+// treated as brand new. The in-degree boost is added on top and grows logarithmically, so a
+// hub page cannot outrank a better text match on links alone.
+func rankingFunctions() []map[string]interface{} {
+	return []map[string]interface{}{
+		{
+			"filter": map[string]interface{}{"exists": map[string]interface{}{"field": "updated_at"}},
+			"gauss": map[string]interface{}{
+				"updated_at": map[string]interface{}{
+					"scale": "30d",
+					"decay": 0.5,
+				},
+			},
+			"weight": 1.2,
+		},
+		{
+			"filter": map[string]interface{}{
+				"bool": map[string]interface{}{
+					"must_not": map[string]interface{}{"exists": map[string]interface{}{"field": "updated_at"}},
+				},
+			},
+			"weight": 0.6,
+		},
+		{
+			"field_value_factor": map[string]interface{}{
+				"field":    "in_degree",
+				"modifier": "log1p",
+				"missing":  0,
+			},
+			"weight": 0.3,
+		},
+	}
+}
+
+// CollapseDuplicates folds near-duplicate articles (same cluster_id, set by the crawler) into the
+// best-scoring one and returns up to three of the others as inner hits. The hit total still counts
+// every article, so the clusters are counted too for paging.
+func CollapseDuplicates(esQuery map[string]interface{}) {
+	esQuery["collapse"] = map[string]interface{}{
+		"field": "cluster_id",
+		"inner_hits": map[string]interface{}{
+			"name":    "duplicates",
+			"size":    4,
+			"_source": []string{"id", "title", "url"},
+		},
+	}
+	aggs, _ := esQuery["aggs"].(map[string]interface{})
+	if aggs == nil {
+		aggs = map[string]interface{}{}
+		esQuery["aggs"] = aggs
+	}
+	aggs["clusters"] = map[string]interface{}{
+		"cardinality": map[string]interface{}{"field": "cluster_id"},
+	}
+}
+
+// ExcludeDeletedArticles filters out articles the crawler tombstoned after they disappeared upstream
+func ExcludeDeletedArticles() []map[string]interface{} {
+	return []map[string]interface{}{
+		{"exists": map[string]interface{}{"field": "deleted_at"}},
+	}
+}
+
+// clauses turns the filters into term queries. A numeric value matches the ID, anything else
+// the exact section or category name.
+func (f Filters) clauses() []map[string]interface{} {
+	clauses := []map[string]interface{}{}
+	for _, filter := range []struct{ value, idField, nameField string }{
+		{f.Section, "section_id", "section_name.keyword"},
+		{f.Category, "category_id", "category_name.keyword"},
+	} {
+		if filter.value == "" {
+			continue
+		}
+		if id, err := strconv.ParseInt(filter.value, 10, 64); err == nil {
+			clauses = append(clauses, map[string]interface{}{"term": map[string]interface{}{filter.idField: id}})
+		} else {
+			clauses = append(clauses, map[string]interface{}{"term": map[string]interface{}{filter.nameField: filter.value}})
+		}
+	}
+	if f.Source != "" {
+		clauses = append(clauses, map[string]interface{}{"term": map[string]interface{}{"source": f.Source}})
+	}
+	if f.Language != "" {
+		clauses = append(clauses, languageClause(strings.ToLower(f.Language)))
+	}
+	return clauses
+}
+
+// languageClause matches articles in the language, plus English articles that have no
+// translation in it. A value with a region (pt-br) matches the locale exactly.
+func languageClause(language string) map[string]interface{} {
+	field, availableField := "language", "available_languages"
+	if strings.Contains(language, "-") {
+		field, availableField = "locale", "available_locales"
+	}
+	if language == "en" || language == "en-us" {
+		return map[string]interface{}{"term": map[string]interface{}{field: language}}
+	}
+
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"should": []map[string]interface{}{
+				{"term": map[string]interface{}{field: language}},
+				{
+					"bool": map[string]interface{}{
+						"filter":   map[string]interface{}{"term": map[string]interface{}{"language": "en"}},
+						"must_not": map[string]interface{}{"term": map[string]interface{}{availableField: language}},
+					},
+				},
+			},
+			"minimum_should_match": 1,
+		},
+	}
+}
+
+// fields adds the crawler's language-analyzed copies (title_pt, body_pt) of the given title and
+// body fields, with the same boosts, so words match across inflections. English copies are
+// searched too for the fallback articles.
+func (f Filters) fields(fields ...string) []string {
+	languages := []string{"en"}
+	if language, _, _ := strings.Cut(strings.ToLower(f.Language), "-"); language != "" && language != "en" {
+		languages = append(languages, language)
+	}
+
+	all := append([]string{}, fields...)
+	for _, field := range fields {
+		name, boost, _ := strings.Cut(field, "^")
+		for _, language := range languages {
+			localized := name + "_" + language
+			if boost != "" {
+				localized += "^" + boost
+			}
+			all = append(all, localized)
+		}
+	}
+	return all
+}
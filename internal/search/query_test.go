@@ -0,0 +1,142 @@
+package search
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestFiltersClauses(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters Filters
+		want    string // clauses as JSON
+	}{
+		{
+			name: "no filters",
+			want: `[]`,
+		},
+		{
+			name:    "section ID and category name",
+			filters: Filters{Section: "360001", Category: "Voice"},
+			want:    `[{"term":{"section_id":360001}},{"term":{"category_name.keyword":"Voice"}}]`,
+		},
+		{
+			name:    "crawled site",
+			filters: Filters{Source: "docs"},
+			want:    `[{"term":{"source":"docs"}}]`,
+		},
+		{
+			name:    "English",
+			filters: Filters{Language: "EN"},
+			want:    `[{"term":{"language":"en"}}]`,
+		},
+		{
+			name:    "language falls back to untranslated English articles",
+			filters: Filters{Language: "pt"},
+			want: `[{"bool":{"minimum_should_match":1,"should":[{"term":{"language":"pt"}},` +
+				`{"bool":{"filter":{"term":{"language":"en"}},"must_not":{"term":{"available_languages":"pt"}}}}]}}]`,
+		},
+		{
+			name:    "locale",
+			filters: Filters{Language: "pt-BR"},
+			want: `[{"bool":{"minimum_should_match":1,"should":[{"term":{"locale":"pt-br"}},` +
+				`{"bool":{"filter":{"term":{"language":"en"}},"must_not":{"term":{"available_locales":"pt-br"}}}}]}}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.filters.clauses())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("clauses = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFiltersFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		want     []string
+	}{
+		{"every language", "", []string{"title^3", "body", "title_en^3", "body_en"}},
+		{"English", "en-us", []string{"title^3", "body", "title_en^3", "body_en"}},
+		{"locale", "pt-br", []string{"title^3", "body", "title_en^3", "title_pt^3", "body_en", "body_pt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Filters{Language: tt.language}).fields("title^3", "body"); !slices.Equal(got, tt.want) {
+				t.Errorf("fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		phrase bool
+	}{
+		{name: "words", query: "call queues"},
+		{name: "quoted phrase", query: `"call queues"`, phrase: true},
+		{name: "unbalanced quote", query: `"call queues`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := Query(tt.query, 20, 10, Filters{Source: "docs"})
+			if body["from"] != 20 || body["size"] != 10 {
+				t.Errorf("from, size = %v, %v, want 20, 10", body["from"], body["size"])
+			}
+
+			query := body["query"].(map[string]interface{})
+			boolQuery, ok := query["bool"].(map[string]interface{})
+			if !tt.phrase {
+				if ok {
+					t.Fatalf("got a phrase query for %q", tt.query)
+				}
+				scored := query["function_score"].(map[string]interface{})["query"].(map[string]interface{})
+				boolQuery = scored["bool"].(map[string]interface{})
+			} else {
+				if !ok {
+					t.Fatalf("got no phrase query for %q", tt.query)
+				}
+				match := boolQuery["must"].(map[string]interface{})["multi_match"].(map[string]interface{})
+				if match["query"] != "call queues" || match["type"] != "phrase" {
+					t.Errorf("phrase match = %v, want the unquoted phrase", match)
+				}
+			}
+
+			filter, _ := json.Marshal(boolQuery["filter"])
+			if string(filter) != `[{"term":{"source":"docs"}}]` {
+				t.Errorf("filter = %s, want the source term", filter)
+			}
+			mustNot, _ := json.Marshal(boolQuery["must_not"])
+			if string(mustNot) != `[{"exists":{"field":"deleted_at"}}]` {
+				t.Errorf("must_not = %s, want deleted articles left out", mustNot)
+			}
+		})
+	}
+}
+
+func TestCollapseDuplicates(t *testing.T) {
+	body := Query("call queues", 0, 10, Filters{})
+	body["aggs"] = map[string]interface{}{"sections": map[string]interface{}{}}
+	CollapseDuplicates(body)
+
+	collapse := body["collapse"].(map[string]interface{})
+	if collapse["field"] != "cluster_id" {
+		t.Errorf("collapsed on %v, want cluster_id", collapse["field"])
+	}
+	aggs := body["aggs"].(map[string]interface{})
+	if _, ok := aggs["sections"]; !ok {
+		t.Error("existing aggregations were dropped")
+	}
+	if _, ok := aggs["clusters"]; !ok {
+		t.Error("clusters are not counted for paging")
+	}
+}
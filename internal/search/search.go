@@ -0,0 +1,53 @@
+// Package search builds the Elasticsearch queries the search programs (api-server.go and
+// web-server.go) send for the crawled articles, and holds the article model they return.
+package search
+
+// Article is a search result, the part of the crawler's indexed document the search programs return
+type Article struct {
+	ID           string   `json:"id"`
+	Title        string   `json:"title"`
+	Body         string   `json:"body"`
+	BodyText     string   `json:"body_text"`
+	CreatedAt    string   `json:"created_at"`
+	UpdatedAt    string   `json:"updated_at"`
+	HTMLURL      string   `json:"url"`
+	SectionID    int64    `json:"section_id"`
+	SectionName  string   `json:"section_name,omitempty"`
+	CategoryID   int64    `json:"category_id,omitempty"`
+	CategoryName string   `json:"category_name,omitempty"`
+	Breadcrumbs  []string `json:"breadcrumbs,omitempty"`
+	// DatesUnreliable is set by the crawler when the page had no date of its own
+	DatesUnreliable bool `json:"dates_unreliable,omitempty"`
+	// BestPassage is the section of the article that matched the query best, filled from inner hits
+	BestPassage *Passage `json:"best_passage,omitempty"`
+	// DuplicateOf is the canonical article when the crawler found this one to be a near-copy
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	// Duplicates are the near-copies folded into this result when search collapses duplicates
+	Duplicates []DuplicateArticle `json:"duplicates,omitempty"`
+	// Source is the name of the crawled site the article comes from, Site the host serving it
+	Source string `json:"source,omitempty"`
+	Site   string `json:"site,omitempty"`
+}
+
+// Passage is a heading-delimited section of an article, indexed by the crawler as a nested document
+type Passage struct {
+	Title  string `json:"title"`
+	Anchor string `json:"anchor,omitempty"`
+	URL    string `json:"url"` // deep link, article URL with the heading anchor
+}
+
+// DuplicateArticle is a near-copy of a search result, returned as an inner hit of the collapse
+type DuplicateArticle struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// Filters narrows a search to one section and/or category of the help center, to one
+// crawled site, and to one language with English standing in for articles not translated into it
+type Filters struct {
+	Section  string
+	Category string
+	Source   string // name of a crawled site
+	Language string // language (pt) or locale (pt-br); empty searches every language
+}
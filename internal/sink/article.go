@@ -0,0 +1,116 @@
+package sink
+
+// Article is a crawled help center article as every sink receives it
+type Article struct {
+	ID           string `json:"id"` // document ID: the site's ID prefix, the Zendesk article ID and the locale outside en-us
+	Title        string `json:"title"`
+	Body         string `json:"body"`                    // sanitized HTML
+	BodyMarkdown string `json:"body_markdown,omitempty"` // headings, lists, tables, code and links kept
+	BodyText     string `json:"body_text,omitempty"`     // plain text for snippets and chat
+	URL          string `json:"url"`
+	CreatedAt    string `json:"created_at,omitempty"`
+	UpdatedAt    string `json:"updated_at,omitempty"`
+	// Where each date was read from, e.g. json-ld or sitemap-lastmod. Dates are left empty rather
+	// than guessed.
+	CreatedAtSource string `json:"created_at_source,omitempty"`
+	UpdatedAtSource string `json:"updated_at_source,omitempty"`
+	// DatesUnreliable is set when neither date came from the page or API itself
+	DatesUnreliable bool   `json:"dates_unreliable,omitempty"`
+	SectionID       int64  `json:"section_id,omitempty"`
+	SectionName     string `json:"section_name,omitempty"`
+	CategoryID      int64  `json:"category_id,omitempty"`
+	CategoryName    string `json:"category_name,omitempty"`
+	// Breadcrumbs is the trail above the article: category, then section(s), outermost first
+	Breadcrumbs []string `json:"breadcrumbs,omitempty"`
+	// Passages are the article's h2/h3 sections, indexed as nested documents for deep links
+	Passages      []Passage    `json:"passages,omitempty"`
+	Images        []Image      `json:"images,omitempty"`
+	Attachments   []Attachment `json:"attachments,omitempty"`
+	InternalLinks []Link       `json:"internal_links,omitempty"` // links to pages on the help center's own host
+	ExternalLinks []Link       `json:"external_links,omitempty"`
+	// SimHash fingerprints the body text for near-duplicate detection, empty for very short bodies
+	SimHash string `json:"simhash,omitempty"`
+	Locale  string `json:"locale,omitempty"` // help center locale, e.g. en-us or pt-br
+	// Translations are the same article in the other crawled locales
+	Translations []Translation `json:"translations,omitempty"`
+	Source       string        `json:"source,omitempty"` // name of the crawled site the article belongs to
+	Site         string        `json:"site,omitempty"`   // host the article is served from
+
+	// The latest snapshot in the revisions index once the Elasticsearch sink has compared the
+	// article with it, for the crawl state to remember
+	Revision     int    `json:"-"`
+	RevisionHash string `json:"-"`
+}
+
+// Translation is another locale's version of an article, from hreflang links or the Zendesk API
+type Translation struct {
+	Locale    string `json:"locale"`
+	URL       string `json:"url"`
+	ArticleID string `json:"article_id"`
+}
+
+type Image struct {
+	Src       string `json:"src"`
+	Alt       string `json:"alt,omitempty"`
+	LocalPath string `json:"local_path,omitempty"` // below MIRROR_DIR when mirroring is on
+}
+
+// Attachment is a downloadable file linked from an article; size and type come from a HEAD request
+type Attachment struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	Size        int64  `json:"size,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	LocalPath   string `json:"local_path,omitempty"`
+}
+
+type Link struct {
+	URL       string `json:"url"`
+	Text      string `json:"text,omitempty"`
+	ArticleID string `json:"article_id,omitempty"` // set for internal links to another article
+}
+
+// Passage is one heading-delimited section of an article
+type Passage struct {
+	Title    string `json:"title"`
+	Anchor   string `json:"anchor,omitempty"` // id of the heading, empty for the introduction
+	URL      string `json:"url"`              // article URL with the anchor
+	Text     string `json:"text"`
+	Position int    `json:"position"` // order within the article, the introduction is 0
+}
+
+// ReleaseEntry is one change announced in a release-notes article, indexed as its own document
+// so "when did feature X ship" can be answered without reading the whole article
+type ReleaseEntry struct {
+	ID           string `json:"id"` // <article id>-<position>
+	ArticleID    string `json:"article_id"`
+	ArticleTitle string `json:"article_title"`
+	ArticleURL   string `json:"article_url"`
+	URL          string `json:"url"` // deep link to the entry's heading when it has an anchor
+	Feature      string `json:"feature"`
+	ProductArea  string `json:"product_area,omitempty"`
+	Type         string `json:"type,omitempty"` // New, Improved, Fixed or Deprecated
+	ReleaseDate  string `json:"release_date,omitempty"`
+	Availability string `json:"availability,omitempty"`
+	Description  string `json:"description"`
+	IndexedAt    string `json:"indexed_at"`
+}
+
+// Revision is a snapshot of an article's normalized text, taken each time the text or title
+// changes, with the diff against the snapshot before it. Revisions outlive their article, so
+// a silently edited or pulled release note keeps a trace.
+type Revision struct {
+	ID            string `json:"id"` // <article id>-<revision>
+	ArticleID     string `json:"article_id"`
+	Revision      int    `json:"revision"` // 1 for the first snapshot we took
+	Title         string `json:"title"`
+	URL           string `json:"url"`
+	Text          string `json:"text"` // body_text at the time
+	TextHash      string `json:"text_hash"`
+	PreviousTitle string `json:"previous_title,omitempty"` // only set when the title changed
+	Diff          string `json:"diff,omitempty"`           // unified diff of the text against the previous revision
+	LinesAdded    int    `json:"lines_added"`
+	LinesRemoved  int    `json:"lines_removed"`
+	UpdatedAt     string `json:"updated_at,omitempty"` // the article's own updated date, when it has one
+	CapturedAt    string `json:"captured_at"`
+}
@@ -0,0 +1,175 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type AzureSearchConfig struct {
+	ServiceName string
+	APIKey      string
+	IndexName   string
+	APIVersion  string
+	BatchSize   int
+}
+
+// AzureSearchSink uploads articles to an Azure Cognitive Search index in batches
+type AzureSearchSink struct {
+	config   AzureSearchConfig
+	client   *http.Client
+	mu       sync.Mutex
+	pending  []AzureDocument
+	urls     map[string]string
+	written  int
+	failures []Failure
+}
+
+type AzureDocument struct {
+	SearchAction string `json:"@search.action"`
+	ID           string `json:"id"`
+	Title        string `json:"title"`
+	Body         string `json:"body"`
+	URL          string `json:"url"`
+	CreatedAt    string `json:"created_at,omitempty"`
+	UpdatedAt    string `json:"updated_at,omitempty"`
+	IndexedAt    string `json:"indexed_at"`
+}
+
+type AzureBatchRequest struct {
+	Value []AzureDocument `json:"value"`
+}
+
+type azureBatchResponse struct {
+	Value []struct {
+		Key          string `json:"key"`
+		Status       bool   `json:"status"`
+		ErrorMessage string `json:"errorMessage"`
+		StatusCode   int    `json:"statusCode"`
+	} `json:"value"`
+}
+
+func NewAzureSearchSink(config AzureSearchConfig) *AzureSearchSink {
+	return &AzureSearchSink{config: config}
+}
+
+func (s *AzureSearchSink) Name() string { return "azure" }
+
+func (s *AzureSearchSink) Open() error {
+	if s.config.ServiceName == "" || s.config.APIKey == "" {
+		return fmt.Errorf("AZURE_SEARCH_SERVICE and AZURE_SEARCH_KEY must be set")
+	}
+	// Azure rejects batches larger than 1000 documents
+	if s.config.BatchSize <= 0 || s.config.BatchSize > 1000 {
+		s.config.BatchSize = 1000
+	}
+	s.client = &http.Client{Timeout: 30 * time.Second}
+	s.urls = make(map[string]string)
+	return nil
+}
+
+func (s *AzureSearchSink) Write(article *Article) error {
+	s.mu.Lock()
+	s.pending = append(s.pending, AzureDocument{
+		SearchAction: "mergeOrUpload",
+		ID:           article.ID,
+		Title:        article.Title,
+		Body:         article.Body,
+		URL:          article.URL,
+		CreatedAt:    article.CreatedAt,
+		UpdatedAt:    article.UpdatedAt,
+		IndexedAt:    time.Now().UTC().Format(time.RFC3339),
+	})
+	s.urls[article.ID] = article.URL
+	full := len(s.pending) >= s.config.BatchSize
+	s.mu.Unlock()
+
+	if full {
+		return s.Flush()
+	}
+	return nil
+}
+
+func (s *AzureSearchSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pending) == 0 {
+		return nil
+	}
+	batch := s.pending
+	s.pending = nil
+
+	jsonData, err := json.Marshal(AzureBatchRequest{Value: batch})
+	if err != nil {
+		return fmt.Errorf("failed to marshal Azure batch: %v", err)
+	}
+
+	azureURL := fmt.Sprintf("https://%s.search.windows.net/indexes/%s/docs/index?api-version=%s",
+		s.config.ServiceName, s.config.IndexName, s.config.APIVersion)
+	req, err := http.NewRequest("POST", azureURL, bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create Azure request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("api-key", s.config.APIKey)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.failBatch(batch, 0, err.Error())
+		return fmt.Errorf("failed to index to Azure: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// 207 means some documents failed, the per-document status tells which
+	if resp.StatusCode != 200 && resp.StatusCode != 207 {
+		s.failBatch(batch, resp.StatusCode, "batch rejected")
+		return fmt.Errorf("Azure indexing failed with status %d", resp.StatusCode)
+	}
+
+	var result azureBatchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		s.failBatch(batch, resp.StatusCode, "unreadable response")
+		return fmt.Errorf("failed to decode Azure response: %v", err)
+	}
+
+	for _, item := range result.Value {
+		if item.Status {
+			s.written++
+			continue
+		}
+		s.failures = append(s.failures, Failure{
+			Sink:   "azure",
+			ID:     item.Key,
+			URL:    s.urls[item.Key],
+			Status: item.StatusCode,
+			Reason: item.ErrorMessage,
+		})
+	}
+
+	fmt.Printf("🔍 Indexed %d documents to Azure Search\n", len(batch))
+	return nil
+}
+
+func (s *AzureSearchSink) failBatch(batch []AzureDocument, status int, reason string) {
+	for _, doc := range batch {
+		s.failures = append(s.failures, Failure{Sink: "azure", ID: doc.ID, URL: doc.URL, Title: doc.Title, Status: status, Reason: reason})
+	}
+}
+
+func (s *AzureSearchSink) Close() error { return nil }
+
+func (s *AzureSearchSink) Written() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.written
+}
+
+func (s *AzureSearchSink) Failures() []Failure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Failure(nil), s.failures...)
+}
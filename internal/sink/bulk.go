@@ -0,0 +1,282 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BulkIndexer buffers documents and writes them with the Elasticsearch _bulk API
+type BulkIndexer struct {
+	config ElasticsearchConfig
+	client *http.Client
+
+	mu           sync.Mutex
+	pending      []BulkItem
+	pendingBytes int
+
+	flushMu  sync.Mutex
+	indexed  map[string]int // successful items per index
+	failures []Failure
+
+	flushNow chan struct{} // asks the background loop for a flush, see Add
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+type BulkItem struct {
+	Action string // index, update or delete
+	Index  string // defaults to the configured article index
+	ID     string
+	URL    string
+	Title  string
+	Source []byte
+}
+
+type bulkResponse struct {
+	Errors bool                        `json:"errors"`
+	Items  []map[string]bulkItemResult `json:"items"`
+}
+
+type bulkItemResult struct {
+	ID     string `json:"_id"`
+	Status int    `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// NewBulkIndexer starts a bulk indexer that also flushes on a timer so a slow crawl
+// doesn't leave documents sitting in the buffer
+func NewBulkIndexer(config ElasticsearchConfig) *BulkIndexer {
+	if config.BulkSize <= 0 {
+		config.BulkSize = 200
+	}
+	if config.BulkBytes <= 0 {
+		config.BulkBytes = 5 * 1024 * 1024
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 5 * time.Second
+	}
+
+	b := &BulkIndexer{
+		config:   config,
+		client:   &http.Client{Timeout: 60 * time.Second},
+		indexed:  make(map[string]int),
+		flushNow: make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go func() {
+		defer close(b.done)
+		ticker := time.NewTicker(config.FlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := b.Flush(); err != nil {
+					fmt.Printf("⚠ Periodic bulk flush failed: %v\n", err)
+				}
+			case <-b.flushNow:
+				if err := b.Flush(); err != nil {
+					fmt.Printf("⚠ Bulk flush failed: %v\n", err)
+				}
+			case <-b.stop:
+				return
+			}
+		}
+	}()
+
+	return b
+}
+
+// Add buffers an item. Once the count or size threshold is reached the background loop flushes,
+// so retries and their backoff don't hold up the caller; only when the buffer grows to several
+// batches, because Elasticsearch can't keep up, does Add wait for a flush itself.
+func (b *BulkIndexer) Add(item BulkItem) error {
+	b.mu.Lock()
+	b.pending = append(b.pending, item)
+	b.pendingBytes += len(item.Source) + len(item.ID) + 64
+	full := len(b.pending) >= b.config.BulkSize || b.pendingBytes >= b.config.BulkBytes
+	backlogged := len(b.pending) >= 4*b.config.BulkSize || b.pendingBytes >= 4*b.config.BulkBytes
+	b.mu.Unlock()
+
+	switch {
+	case backlogged:
+		return b.Flush()
+	case full:
+		select {
+		case b.flushNow <- struct{}{}:
+		default: // a flush is already requested
+		}
+	}
+	return nil
+}
+
+// Flush sends every buffered item, retrying the ones Elasticsearch rejected as transient
+func (b *BulkIndexer) Flush() error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	items := b.pending
+	b.pending = nil
+	b.pendingBytes = 0
+	b.mu.Unlock()
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	var lastErr error
+	for attempt := 0; len(items) > 0; attempt++ {
+		if attempt > 0 {
+			// Exponential backoff: 1s, 2s, 4s...
+			backoff := time.Duration(1<<uint(attempt-1)) * time.Second
+			fmt.Printf("🔁 Retrying %d bulk items in %v (attempt %d/%d)\n", len(items), backoff, attempt, b.config.MaxRetries)
+			time.Sleep(backoff)
+		}
+
+		retry, err := b.send(items)
+		lastErr = err
+		items = retry
+
+		if attempt >= b.config.MaxRetries {
+			break
+		}
+	}
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	// Whatever is left ran out of retries
+	for _, item := range items {
+		b.failures = append(b.failures, Failure{Sink: "elasticsearch", ID: item.ID, URL: item.URL, Title: item.Title, Reason: lastErr.Error()})
+	}
+	return fmt.Errorf("%d documents still failing after %d retries: %v", len(items), b.config.MaxRetries, lastErr)
+}
+
+// send performs one _bulk request and returns the items worth retrying
+func (b *BulkIndexer) send(items []BulkItem) ([]BulkItem, error) {
+	var payload bytes.Buffer
+	for _, item := range items {
+		meta := map[string]map[string]string{
+			item.Action: {"_index": b.indexOf(item), "_id": item.ID},
+		}
+		metaJSON, err := json.Marshal(meta)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal bulk action: %v", err)
+		}
+		payload.Write(metaJSON)
+		payload.WriteByte('\n')
+		if item.Action != "delete" {
+			payload.Write(item.Source)
+			payload.WriteByte('\n')
+		}
+	}
+
+	req, err := NewRequest(b.config, "POST", fmt.Sprintf("%s/_bulk", b.config.URL), &payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bulk request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		// The whole request failed, so every item is retryable
+		return items, fmt.Errorf("bulk request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 429 || resp.StatusCode >= 500 {
+		return items, fmt.Errorf("bulk request returned status %d", resp.StatusCode)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		for _, item := range items {
+			b.failures = append(b.failures, Failure{Sink: "elasticsearch", ID: item.ID, URL: item.URL, Title: item.Title, Status: resp.StatusCode, Reason: strings.TrimSpace(string(body))})
+		}
+		return nil, nil
+	}
+
+	var result bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return items, fmt.Errorf("failed to decode bulk response: %v", err)
+	}
+
+	// Items in the response are in the same order as the request
+	var retry []BulkItem
+	succeeded := 0
+	for i, entry := range result.Items {
+		if i >= len(items) {
+			break
+		}
+		for _, itemResult := range entry {
+			switch {
+			case itemResult.Status >= 200 && itemResult.Status < 300:
+				succeeded++
+				b.indexed[b.indexOf(items[i])]++
+			case items[i].Action == "delete" && itemResult.Status == 404:
+				succeeded++
+				b.indexed[b.indexOf(items[i])]++
+			case itemResult.Status == 429 || itemResult.Status >= 500:
+				retry = append(retry, items[i])
+			default:
+				reason := "unknown error"
+				if itemResult.Error != nil {
+					reason = fmt.Sprintf("%s: %s", itemResult.Error.Type, itemResult.Error.Reason)
+				}
+				b.failures = append(b.failures, Failure{Sink: "elasticsearch", ID: items[i].ID, URL: items[i].URL, Title: items[i].Title, Status: itemResult.Status, Reason: reason})
+			}
+		}
+	}
+
+	// Items the response doesn't account for were not confirmed, so they count as failed
+	if len(result.Items) < len(items) {
+		for _, item := range items[len(result.Items):] {
+			b.failures = append(b.failures, Failure{Sink: "elasticsearch", ID: item.ID, URL: item.URL, Title: item.Title, Status: resp.StatusCode, Reason: "missing from the bulk response"})
+		}
+	}
+
+	fmt.Printf("📦 Bulk indexed %d/%d documents to Elasticsearch\n", succeeded, len(items))
+	if len(retry) > 0 {
+		return retry, fmt.Errorf("%d items rejected with a retryable status", len(retry))
+	}
+	return nil, nil
+}
+
+// Close stops the flush timer and sends any remaining documents
+func (b *BulkIndexer) Close() error {
+	close(b.stop)
+	<-b.done
+	return b.Flush()
+}
+
+func (b *BulkIndexer) Failures() []Failure {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+	return append([]Failure(nil), b.failures...)
+}
+
+// IndexedIn is how many items were written successfully to one index
+func (b *BulkIndexer) IndexedIn(index string) int {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+	return b.indexed[index]
+}
+
+func (b *BulkIndexer) indexOf(item BulkItem) string {
+	if item.Index != "" {
+		return item.Index
+	}
+	return b.config.Index
+}
@@ -0,0 +1,182 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// bulkReply is how the fake cluster answers one _bulk request
+type bulkReply struct {
+	status int   // of the whole request
+	items  []int // status per item; a shorter list leaves the remaining items out of the response
+}
+
+// bulkServer answers each _bulk request with the next scripted reply and records the IDs it was sent
+func bulkServer(t *testing.T, replies []bulkReply) (*httptest.Server, func() [][]string) {
+	var mu sync.Mutex
+	var requests [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var ids []string
+		decoder := json.NewDecoder(r.Body)
+		for decoder.More() {
+			var action map[string]struct {
+				ID string `json:"_id"`
+			}
+			if err := decoder.Decode(&action); err != nil {
+				t.Errorf("bad bulk payload: %v", err)
+				return
+			}
+			for name, meta := range action {
+				ids = append(ids, meta.ID)
+				if name != "delete" {
+					var source json.RawMessage
+					decoder.Decode(&source)
+				}
+			}
+		}
+		requests = append(requests, ids)
+		if len(requests) > len(replies) {
+			t.Errorf("unexpected bulk request #%d for %v", len(requests), ids)
+			http.Error(w, "unexpected request", http.StatusInternalServerError)
+			return
+		}
+
+		reply := replies[len(requests)-1]
+		if reply.status < 200 || reply.status >= 300 {
+			w.WriteHeader(reply.status)
+			w.Write([]byte(`{"error":"illegal_argument_exception"}` + "\n"))
+			return
+		}
+		var items []map[string]interface{}
+		for _, status := range reply.items {
+			result := map[string]interface{}{"status": status}
+			if status >= 400 {
+				result["error"] = map[string]string{"type": "mapper_parsing_exception", "reason": "failed to parse field [title]"}
+			}
+			items = append(items, map[string]interface{}{"index": result})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"errors": true, "items": items})
+	}))
+	t.Cleanup(server.Close)
+	return server, func() [][]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func TestBulkIndexer(t *testing.T) {
+	doc := func(id string) BulkItem {
+		return BulkItem{Action: "index", ID: id, URL: "https://help.example.com/hc/en-us/articles/" + id, Title: "Article " + id, Source: []byte(`{"title":"Article ` + id + `"}`)}
+	}
+	failed := func(id string, status int, reason string) Failure {
+		return Failure{Sink: "elasticsearch", ID: id, URL: "https://help.example.com/hc/en-us/articles/" + id, Title: "Article " + id, Status: status, Reason: reason}
+	}
+	release := doc("100-1")
+	release.Index = "releases"
+
+	tests := []struct {
+		name     string
+		items    []BulkItem
+		replies  []bulkReply
+		requests [][]string // IDs sent per request
+		failures []Failure
+		indexed  map[string]int
+		wantErr  bool
+	}{
+		{
+			name:     "item errors",
+			items:    []BulkItem{doc("100"), doc("200"), release},
+			replies:  []bulkReply{{status: 200, items: []int{201, 400, 201}}},
+			requests: [][]string{{"100", "200", "100-1"}},
+			failures: []Failure{failed("200", 400, "mapper_parsing_exception: failed to parse field [title]")},
+			indexed:  map[string]int{"articles": 1, "releases": 1},
+		},
+		{
+			name:     "retryable items are retried",
+			items:    []BulkItem{doc("100"), doc("200")},
+			replies:  []bulkReply{{status: 200, items: []int{201, 429}}, {status: 200, items: []int{201}}},
+			requests: [][]string{{"100", "200"}, {"200"}},
+			indexed:  map[string]int{"articles": 2},
+		},
+		{
+			name:     "retries run out",
+			items:    []BulkItem{doc("100")},
+			replies:  []bulkReply{{status: 200, items: []int{503}}, {status: 200, items: []int{503}}},
+			requests: [][]string{{"100"}, {"100"}},
+			failures: []Failure{failed("100", 0, "1 items rejected with a retryable status")},
+			indexed:  map[string]int{"articles": 0},
+			wantErr:  true,
+		},
+		{
+			name:     "items missing from the response",
+			items:    []BulkItem{doc("100"), doc("200"), doc("300")},
+			replies:  []bulkReply{{status: 200, items: []int{201}}},
+			requests: [][]string{{"100", "200", "300"}},
+			failures: []Failure{failed("200", 200, "missing from the bulk response"), failed("300", 200, "missing from the bulk response")},
+			indexed:  map[string]int{"articles": 1},
+		},
+		{
+			name:     "rejected request",
+			items:    []BulkItem{doc("100"), doc("200")},
+			replies:  []bulkReply{{status: 400}},
+			requests: [][]string{{"100", "200"}},
+			failures: []Failure{
+				failed("100", 400, `{"error":"illegal_argument_exception"}`),
+				failed("200", 400, `{"error":"illegal_argument_exception"}`),
+			},
+			indexed: map[string]int{"articles": 0},
+		},
+		{
+			name:     "overloaded cluster",
+			items:    []BulkItem{doc("100"), doc("200")},
+			replies:  []bulkReply{{status: 503}, {status: 200, items: []int{201, 201}}},
+			requests: [][]string{{"100", "200"}, {"100", "200"}},
+			indexed:  map[string]int{"articles": 2},
+		},
+		{
+			name:     "deleting a document that is already gone",
+			items:    []BulkItem{{Action: "delete", ID: "100"}},
+			replies:  []bulkReply{{status: 200, items: []int{404}}},
+			requests: [][]string{{"100"}},
+			indexed:  map[string]int{"articles": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := bulkServer(t, tt.replies)
+			// One retry keeps the backoff at a second; the long interval leaves flushing to Close
+			indexer := NewBulkIndexer(ElasticsearchConfig{URL: server.URL, Index: "articles", MaxRetries: 1, FlushInterval: time.Hour})
+			for _, item := range tt.items {
+				if err := indexer.Add(item); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := indexer.Close()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Close error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if got := requests(); fmt.Sprint(got) != fmt.Sprint(tt.requests) {
+				t.Errorf("requests = %v, want %v", got, tt.requests)
+			}
+			if got := indexer.Failures(); !slices.Equal(got, tt.failures) {
+				t.Errorf("failures = %+v, want %+v", got, tt.failures)
+			}
+			for index, want := range tt.indexed {
+				if got := indexer.IndexedIn(index); got != want {
+					t.Errorf("IndexedIn(%q) = %d, want %d", index, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,423 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ElasticsearchConfig is where and how the Elasticsearch sink and the bulk indexer write
+type ElasticsearchConfig struct {
+	Enabled        bool
+	URL            string
+	Index          string
+	ReleasesIndex  string // release entries parsed out of release-notes articles; empty disables them
+	RevisionsIndex string // snapshots and diffs of every article change; empty disables them
+	Username       string
+	Password       string
+	BulkSize       int           // flush after this many buffered documents
+	BulkBytes      int           // flush once the NDJSON payload reaches this size
+	FlushInterval  time.Duration // flush at least this often while documents are buffered
+	MaxRetries     int           // retries for items rejected with 429/5xx
+}
+
+// ElasticsearchSink indexes articles through the bulk indexer
+type ElasticsearchSink struct {
+	config              ElasticsearchConfig
+	revisions           RevisionLookup // knows the latest revision of each article
+	parseReleaseEntries func(*Article) []ReleaseEntry
+	indexer             *BulkIndexer
+
+	// Articles written since the last flush, whose entries other than the ones just written are
+	// deleted in one request, see clearReleaseEntries
+	releaseArticles []string
+	releaseEntries  []string
+}
+
+// RevisionLookup tells the latest revision stored for an article and the hash of its text, as
+// far as the caller remembers; 0 when it doesn't know
+type RevisionLookup interface {
+	Revision(articleURL, documentID string) (int, string)
+}
+
+// NewElasticsearchSink indexes articles to config.Index. revisions saves a look-up in the
+// revisions index for articles whose text hasn't changed, and releaseEntries parses the release
+// entries of an article, none for one that isn't release notes; either may be nil.
+func NewElasticsearchSink(config ElasticsearchConfig, revisions RevisionLookup, releaseEntries func(*Article) []ReleaseEntry) *ElasticsearchSink {
+	return &ElasticsearchSink{config: config, revisions: revisions, parseReleaseEntries: releaseEntries}
+}
+
+// articleMappingProperties is shared by index creation and the mapping update for existing indexes
+var articleMappingProperties = `{
+				"id": {"type": "keyword"},
+				"title": {"type": "text", "analyzer": "standard"},
+				"body": {"type": "text", "analyzer": "standard"},
+				"body_markdown": {"type": "text", "index": false},
+				"body_text": {"type": "text", "analyzer": "standard"},
+				"url": {"type": "keyword"},
+				"created_at": {"type": "date"},
+				"updated_at": {"type": "date"},
+				"created_at_source": {"type": "keyword"},
+				"updated_at_source": {"type": "keyword"},
+				"dates_unreliable": {"type": "boolean"},
+				"indexed_at": {"type": "date"},
+				"deleted_at": {"type": "date"},
+				"section_id": {"type": "long"},
+				"section_name": {"type": "text", "fields": {"keyword": {"type": "keyword"}}},
+				"category_id": {"type": "long"},
+				"category_name": {"type": "text", "fields": {"keyword": {"type": "keyword"}}},
+				"breadcrumbs": {"type": "keyword"},
+				"passages": {
+					"type": "nested",
+					"properties": {
+						"title": {"type": "text", "analyzer": "standard"},
+						"anchor": {"type": "keyword"},
+						"url": {"type": "keyword"},
+						"text": {"type": "text", "analyzer": "standard"},
+						"position": {"type": "integer"}
+					}
+				},
+				"images": {
+					"properties": {
+						"src": {"type": "keyword"},
+						"alt": {"type": "text"},
+						"local_path": {"type": "keyword"}
+					}
+				},
+				"attachments": {
+					"properties": {
+						"name": {"type": "text", "fields": {"keyword": {"type": "keyword"}}},
+						"url": {"type": "keyword"},
+						"size": {"type": "long"},
+						"content_type": {"type": "keyword"},
+						"local_path": {"type": "keyword"}
+					}
+				},
+				"internal_links": {
+					"properties": {
+						"url": {"type": "keyword"},
+						"text": {"type": "text"},
+						"article_id": {"type": "keyword"}
+					}
+				},
+				"external_links": {
+					"properties": {
+						"url": {"type": "keyword"},
+						"text": {"type": "text"}
+					}
+				},
+				"in_degree": {"type": "integer"},
+				"linked_from": {"type": "keyword"},
+				"simhash": {"type": "keyword"},
+				"cluster_id": {"type": "keyword"},
+				"duplicate_of": {"type": "keyword"},
+				"locale": {"type": "keyword"},
+				"language": {"type": "keyword"},
+				"translations": {
+					"properties": {
+						"locale": {"type": "keyword"},
+						"url": {"type": "keyword"},
+						"article_id": {"type": "keyword"}
+					}
+				},
+				"available_locales": {"type": "keyword"},
+				"available_languages": {"type": "keyword"},
+				"source": {"type": "keyword"},
+				"site": {"type": "keyword"},` + languageFieldMappings() + `
+			}`
+
+// languageAnalyzers are the Elasticsearch language analyzers used for the title_<language> and
+// body_<language> copies of each article, which stem and drop stop words in the article's own
+// language. Other languages are searched through the standard-analyzed fields only.
+var languageAnalyzers = map[string]string{
+	"en": "english",
+	"pt": "portuguese",
+	"es": "spanish",
+	"fr": "french",
+	"de": "german",
+	"it": "italian",
+	"nl": "dutch",
+	"ja": "cjk",
+	"ko": "cjk",
+	"zh": "cjk",
+}
+
+// languageFieldMappings renders the mapping of the per-language title and body fields
+func languageFieldMappings() string {
+	languages := make([]string, 0, len(languageAnalyzers))
+	for language := range languageAnalyzers {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+
+	var fields []string
+	for _, language := range languages {
+		analyzer := languageAnalyzers[language]
+		fields = append(fields,
+			fmt.Sprintf(`"title_%s": {"type": "text", "analyzer": %q}`, language, analyzer),
+			fmt.Sprintf(`"body_%s": {"type": "text", "analyzer": %q}`, language, analyzer),
+		)
+	}
+	return "\n\t\t\t\t" + strings.Join(fields, ",\n\t\t\t\t")
+}
+
+// updateMapping adds fields introduced since an existing index was created.
+// Elasticsearch only accepts new fields here, so it is safe to run on every crawl.
+func updateMapping(config ElasticsearchConfig, client *http.Client, index, properties string) error {
+	mappingURL := fmt.Sprintf("%s/%s/_mapping", config.URL, index)
+	req, err := NewRequest(config, "PUT", mappingURL, strings.NewReader(`{"properties": `+properties+`}`))
+	if err != nil {
+		return fmt.Errorf("failed to create mapping request: %v", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to update mapping: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to update mapping, status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// createIndex creates index with the given mapping properties, or brings an
+// existing index's mapping up to date
+func createIndex(config ElasticsearchConfig, index, properties string) error {
+	if !config.Enabled {
+		return nil
+	}
+
+	// Check if index exists first
+	checkURL := fmt.Sprintf("%s/%s", config.URL, index)
+	req, err := http.NewRequest("HEAD", checkURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create HEAD request: %v", err)
+	}
+
+	if config.Username != "" && config.Password != "" {
+		req.SetBasicAuth(config.Username, config.Password)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to check index existence: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		fmt.Printf("📋 Elasticsearch index '%s' already exists\n", index)
+		return updateMapping(config, client, index, properties)
+	}
+
+	// Create index with mapping
+	indexMapping := `{
+		"mappings": {
+			"properties": ` + properties + `
+		}
+	}`
+
+	createURL := fmt.Sprintf("%s/%s", config.URL, index)
+	req, err = http.NewRequest("PUT", createURL, strings.NewReader(indexMapping))
+	if err != nil {
+		return fmt.Errorf("failed to create PUT request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if config.Username != "" && config.Password != "" {
+		req.SetBasicAuth(config.Username, config.Password)
+	}
+
+	resp, err = client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to create index: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to create index, status: %d", resp.StatusCode)
+	}
+
+	fmt.Printf("✅ Created Elasticsearch index '%s'\n", index)
+	return nil
+}
+
+func (s *ElasticsearchSink) Name() string { return "elasticsearch" }
+
+func (s *ElasticsearchSink) Open() error {
+	if err := createIndex(s.config, s.config.Index, articleMappingProperties); err != nil {
+		// Indexing can still succeed if the index exists and only the HEAD check failed
+		fmt.Printf("⚠ Failed to create Elasticsearch index: %v\n", err)
+	}
+	if s.config.ReleasesIndex != "" {
+		if err := createIndex(s.config, s.config.ReleasesIndex, releaseEntryMappingProperties); err != nil {
+			fmt.Printf("⚠ Failed to create release entries index: %v\n", err)
+		}
+	}
+	if s.config.RevisionsIndex != "" {
+		if err := createIndex(s.config, s.config.RevisionsIndex, revisionMappingProperties); err != nil {
+			fmt.Printf("⚠ Failed to create revisions index: %v\n", err)
+		}
+	}
+	s.indexer = NewBulkIndexer(s.config)
+	return nil
+}
+
+func (s *ElasticsearchSink) Write(article *Article) error {
+	jsonData, err := json.Marshal(buildDocument(article))
+	if err != nil {
+		return fmt.Errorf("failed to marshal document: %v", err)
+	}
+
+	if err := s.indexer.Add(BulkItem{
+		Action: "index",
+		ID:     article.ID,
+		URL:    article.URL,
+		Title:  article.Title,
+		Source: jsonData,
+	}); err != nil {
+		return err
+	}
+
+	if s.config.ReleasesIndex != "" {
+		if err := s.writeReleaseEntries(article); err != nil {
+			return err
+		}
+	}
+
+	if s.config.RevisionsIndex == "" {
+		return nil
+	}
+	return s.writeRevision(article)
+}
+
+func (s *ElasticsearchSink) Flush() error {
+	s.clearReleaseEntries()
+	return s.indexer.Flush()
+}
+
+func (s *ElasticsearchSink) Close() error {
+	s.clearReleaseEntries()
+	return s.indexer.Close()
+}
+
+func (s *ElasticsearchSink) Written() int { return s.indexer.IndexedIn(s.config.Index) }
+
+func (s *ElasticsearchSink) Failures() []Failure { return s.indexer.Failures() }
+
+// buildDocument transforms article data for Elasticsearch
+func buildDocument(article *Article) map[string]interface{} {
+	doc := map[string]interface{}{
+		"id":               article.ID,
+		"title":            article.Title,
+		"body":             article.Body,
+		"body_markdown":    article.BodyMarkdown,
+		"body_text":        article.BodyText,
+		"url":              article.URL,
+		"indexed_at":       time.Now().UTC().Format(time.RFC3339),
+		"dates_unreliable": article.DatesUnreliable,
+		// Every article starts as its own cluster, the near-duplicate pass re-points copies
+		"cluster_id": article.ID,
+	}
+	if article.SimHash != "" {
+		doc["simhash"] = article.SimHash
+	}
+	if article.Source != "" {
+		doc["source"] = article.Source
+		doc["site"] = article.Site
+	}
+
+	// Search filters on language and falls back to English for articles with no translation in
+	// the requested one, which the available_* fields tell it
+	if article.Locale != "" {
+		language := languageOf(article.Locale)
+		availableLocales := []string{article.Locale}
+		availableLanguages := []string{language}
+		for _, translation := range article.Translations {
+			availableLocales = append(availableLocales, translation.Locale)
+			if !slices.Contains(availableLanguages, languageOf(translation.Locale)) {
+				availableLanguages = append(availableLanguages, languageOf(translation.Locale))
+			}
+		}
+		doc["locale"] = article.Locale
+		doc["language"] = language
+		doc["available_locales"] = availableLocales
+		doc["available_languages"] = availableLanguages
+		if len(article.Translations) > 0 {
+			doc["translations"] = article.Translations
+		}
+		if _, ok := languageAnalyzers[language]; ok {
+			doc["title_"+language] = article.Title
+			doc["body_"+language] = article.BodyText
+		}
+	}
+
+	// Unknown dates are left out instead of indexed as empty strings or the crawl time
+	if article.CreatedAt != "" {
+		doc["created_at"] = article.CreatedAt
+		doc["created_at_source"] = article.CreatedAtSource
+	}
+	if article.UpdatedAt != "" {
+		doc["updated_at"] = article.UpdatedAt
+		doc["updated_at_source"] = article.UpdatedAtSource
+	}
+
+	// Pages without breadcrumbs (or a section the API can't resolve) leave these out
+	if len(article.Breadcrumbs) > 0 {
+		doc["breadcrumbs"] = article.Breadcrumbs
+	}
+	if len(article.Passages) > 0 {
+		doc["passages"] = article.Passages
+	}
+	if len(article.Images) > 0 {
+		doc["images"] = article.Images
+	}
+	if len(article.Attachments) > 0 {
+		doc["attachments"] = article.Attachments
+	}
+	if len(article.InternalLinks) > 0 {
+		doc["internal_links"] = article.InternalLinks
+	}
+	if len(article.ExternalLinks) > 0 {
+		doc["external_links"] = article.ExternalLinks
+	}
+	if article.SectionID != 0 {
+		doc["section_id"] = article.SectionID
+		doc["section_name"] = article.SectionName
+	}
+	if article.CategoryID != 0 {
+		doc["category_id"] = article.CategoryID
+		doc["category_name"] = article.CategoryName
+	}
+
+	return doc
+}
+
+// languageOf returns the language part of a locale: pt for pt-br
+func languageOf(locale string) string {
+	language, _, _ := strings.Cut(locale, "-")
+	return language
+}
+
+// NewRequest builds a JSON request to Elasticsearch carrying the configured credentials
+func NewRequest(config ElasticsearchConfig, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if config.Username != "" && config.Password != "" {
+		req.SetBasicAuth(config.Username, config.Password)
+	}
+
+	return req, nil
+}
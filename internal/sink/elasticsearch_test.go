@@ -0,0 +1,233 @@
+package sink
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// indexedItem is one action of a _bulk request as the fake cluster received it
+type indexedItem struct {
+	Action string
+	Index  string
+	ID     string
+	Source json.RawMessage
+}
+
+// elasticsearchServer accepts every request like an empty cluster would, serves the documents
+// it is given by path, and records the bulk actions and delete-by-query bodies it receives
+type elasticsearchServer struct {
+	*httptest.Server
+	mu            sync.Mutex
+	documents     map[string]string // response bodies by path, e.g. /revisions/_doc/100-1
+	bulk          []indexedItem
+	deleteByQuery []string
+	requests      []string // method and path of every request
+}
+
+func newElasticsearchServer(t *testing.T) *elasticsearchServer {
+	es := &elasticsearchServer{documents: make(map[string]string)}
+	es.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		es.mu.Lock()
+		defer es.mu.Unlock()
+		es.requests = append(es.requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.URL.Path == "/_bulk":
+			var items []map[string]map[string]int
+			decoder := json.NewDecoder(r.Body)
+			for decoder.More() {
+				var action map[string]struct {
+					Index string `json:"_index"`
+					ID    string `json:"_id"`
+				}
+				if err := decoder.Decode(&action); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				for name, meta := range action {
+					item := indexedItem{Action: name, Index: meta.Index, ID: meta.ID}
+					if name != "delete" {
+						decoder.Decode(&item.Source)
+					}
+					es.bulk = append(es.bulk, item)
+					items = append(items, map[string]map[string]int{name: {"status": 201}})
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+		case strings.HasSuffix(r.URL.Path, "/_delete_by_query"):
+			body, _ := io.ReadAll(r.Body)
+			es.deleteByQuery = append(es.deleteByQuery, string(body))
+			w.Write([]byte(`{"deleted":0}`))
+		case es.documents[r.URL.Path] != "":
+			w.Write([]byte(es.documents[r.URL.Path]))
+		case strings.Contains(r.URL.Path, "/_doc/"):
+			http.NotFound(w, r)
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	t.Cleanup(es.Close)
+	return es
+}
+
+// indexed returns the items written to one index
+func (es *elasticsearchServer) indexed(index string) []indexedItem {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	var items []indexedItem
+	for _, item := range es.bulk {
+		if item.Index == index {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func TestElasticsearchSinkReleaseEntries(t *testing.T) {
+	es := newElasticsearchServer(t)
+	releaseNotes := &Article{ID: "100", Title: "Release Notes March 2024", URL: "https://help.example.com/hc/en-us/articles/100"}
+	// Stopped reading as release notes, so the entries it had are cleared
+	renamed := &Article{ID: "200", Title: "Flow versioning", URL: "https://help.example.com/hc/en-us/articles/200"}
+	entries := func(article *Article) []ReleaseEntry {
+		if article != releaseNotes {
+			return nil
+		}
+		return []ReleaseEntry{
+			{ID: "100-1", ArticleID: "100", Feature: "Flow versioning"},
+			{ID: "100-2", ArticleID: "100", Feature: "Timeouts"},
+		}
+	}
+	sink := NewElasticsearchSink(ElasticsearchConfig{URL: es.URL, Index: "articles", ReleasesIndex: "releases"}, nil, entries)
+	if err := sink.Open(); err != nil {
+		t.Fatal(err)
+	}
+	for _, article := range []*Article{releaseNotes, renamed} {
+		if err := sink.Write(article); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var released []string
+	for _, item := range es.indexed("releases") {
+		released = append(released, item.ID)
+	}
+	if want := []string{"100-1", "100-2"}; !slices.Equal(released, want) {
+		t.Errorf("release entries indexed = %v, want %v", released, want)
+	}
+	if got := sink.ReleaseEntries(); got != 2 {
+		t.Errorf("ReleaseEntries = %d, want 2", got)
+	}
+	if got := sink.Written(); got != 2 {
+		t.Errorf("Written = %d, want 2", got)
+	}
+
+	if len(es.deleteByQuery) != 1 {
+		t.Fatalf("got %d delete-by-query requests, want 1", len(es.deleteByQuery))
+	}
+	var query struct {
+		Query struct {
+			Bool struct {
+				Filter struct {
+					Terms struct {
+						ArticleID []string `json:"article_id"`
+					} `json:"terms"`
+				} `json:"filter"`
+				MustNot struct {
+					IDs struct {
+						Values []string `json:"values"`
+					} `json:"ids"`
+				} `json:"must_not"`
+			} `json:"bool"`
+		} `json:"query"`
+	}
+	if err := json.Unmarshal([]byte(es.deleteByQuery[0]), &query); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := query.Query.Bool.Filter.Terms.ArticleID, []string{"100", "200"}; !slices.Equal(got, want) {
+		t.Errorf("entries cleared for articles %v, want %v", got, want)
+	}
+	if got, want := query.Query.Bool.MustNot.IDs.Values, []string{"100-1", "100-2"}; !slices.Equal(got, want) {
+		t.Errorf("entries kept %v, want %v", got, want)
+	}
+}
+
+func TestBuildDocument(t *testing.T) {
+	tests := []struct {
+		name    string
+		article Article
+		want    map[string]interface{} // fields checked, nil values must be absent
+	}{
+		{
+			name: "translated article",
+			article: Article{
+				ID: "100-pt-br", Title: "Filas", BodyText: "Filas de chamadas", Locale: "pt-br",
+				Translations: []Translation{{Locale: "en-us", ArticleID: "100"}, {Locale: "pt-pt", ArticleID: "100-pt-pt"}},
+			},
+			want: map[string]interface{}{
+				"language":            "pt",
+				"available_locales":   []string{"pt-br", "en-us", "pt-pt"},
+				"available_languages": []string{"pt", "en"},
+				"title_pt":            "Filas",
+				"body_pt":             "Filas de chamadas",
+				"cluster_id":          "100-pt-br",
+			},
+		},
+		{
+			name:    "language without an analyzer",
+			article: Article{ID: "100-pl", Title: "Kolejki", Locale: "pl"},
+			want: map[string]interface{}{
+				"language":     "pl",
+				"title_pl":     nil,
+				"translations": nil,
+			},
+		},
+		{
+			name: "dates with their sources",
+			article: Article{
+				ID: "100", CreatedAt: "2024-03-01T00:00:00Z", CreatedAtSource: "json-ld",
+				UpdatedAt: "2024-03-02T00:00:00Z", UpdatedAtSource: "sitemap-lastmod",
+			},
+			want: map[string]interface{}{
+				"created_at":        "2024-03-01T00:00:00Z",
+				"created_at_source": "json-ld",
+				"updated_at":        "2024-03-02T00:00:00Z",
+				"updated_at_source": "sitemap-lastmod",
+				"locale":            nil,
+			},
+		},
+		{
+			name:    "undated",
+			article: Article{ID: "100", DatesUnreliable: true},
+			want: map[string]interface{}{
+				"created_at":       nil,
+				"updated_at":       nil,
+				"dates_unreliable": true,
+				"section_id":       nil,
+				"breadcrumbs":      nil,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := buildDocument(&tt.article)
+			for field, want := range tt.want {
+				got, ok := doc[field]
+				switch {
+				case want == nil && ok:
+					t.Errorf("%s = %v, want it left out", field, got)
+				case want != nil && !reflect.DeepEqual(got, want):
+					t.Errorf("%s = %#v, want %#v", field, got, want)
+				}
+			}
+		})
+	}
+}
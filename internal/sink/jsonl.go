@@ -0,0 +1,66 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// JSONLSink appends one JSON article per line to a file
+type JSONLSink struct {
+	path    string
+	mu      sync.Mutex
+	file    *os.File
+	writer  *bufio.Writer
+	written int
+}
+
+func NewJSONLSink(path string) *JSONLSink {
+	return &JSONLSink{path: path}
+}
+
+func (s *JSONLSink) Name() string { return "jsonl" }
+
+func (s *JSONLSink) Open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", s.path, err)
+	}
+	s.file = file
+	s.writer = bufio.NewWriter(file)
+	return nil
+}
+
+func (s *JSONLSink) Write(article *Article) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := json.NewEncoder(s.writer).Encode(article); err != nil {
+		return fmt.Errorf("failed to write article to %s: %v", s.path, err)
+	}
+	s.written++
+	return nil
+}
+
+func (s *JSONLSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writer.Flush()
+}
+
+func (s *JSONLSink) Close() error {
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	return s.file.Close()
+}
+
+func (s *JSONLSink) Written() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.written
+}
+
+func (s *JSONLSink) Failures() []Failure { return nil }
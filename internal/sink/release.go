@@ -0,0 +1,127 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxPendingReleaseArticles is how many written articles share one delete-by-query
+const maxPendingReleaseArticles = 500
+
+// releaseEntryMappingProperties is the mapping of the release entries index
+const releaseEntryMappingProperties = `{
+				"id": {"type": "keyword"},
+				"article_id": {"type": "keyword"},
+				"article_title": {"type": "text"},
+				"article_url": {"type": "keyword"},
+				"url": {"type": "keyword"},
+				"feature": {"type": "text", "fields": {"keyword": {"type": "keyword"}}},
+				"product_area": {"type": "text", "fields": {"keyword": {"type": "keyword"}}},
+				"type": {"type": "keyword"},
+				"release_date": {"type": "date"},
+				"availability": {"type": "text"},
+				"description": {"type": "text"},
+				"indexed_at": {"type": "date"}
+			}`
+
+// writeReleaseEntries replaces the entries indexed for an article: a release-notes article gets
+// its current ones, any other article loses those it had while it still read as release notes.
+// Entries dropped this way are deleted with the next batch, see clearReleaseEntries.
+func (s *ElasticsearchSink) writeReleaseEntries(article *Article) error {
+	var entries []ReleaseEntry
+	if s.parseReleaseEntries != nil {
+		entries = s.parseReleaseEntries(article)
+	}
+
+	s.releaseArticles = append(s.releaseArticles, article.ID)
+	for _, entry := range entries {
+		s.releaseEntries = append(s.releaseEntries, entry.ID)
+	}
+	if len(s.releaseArticles) >= maxPendingReleaseArticles {
+		s.clearReleaseEntries()
+	}
+
+	for _, entry := range entries {
+		jsonData, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to marshal release entry: %v", err)
+		}
+		if err := s.indexer.Add(BulkItem{
+			Action: "index",
+			Index:  s.config.ReleasesIndex,
+			ID:     entry.ID,
+			URL:    article.URL,
+			Title:  article.Title + " › " + entry.Feature,
+			Source: jsonData,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if len(entries) > 0 {
+		fmt.Printf("📝 %s: %d release entries\n", article.Title, len(entries))
+	}
+	return nil
+}
+
+// clearReleaseEntries deletes the old entries of the articles written since the last call in one
+// delete-by-query. The entries just written are excluded by ID, so it doesn't matter whether the
+// bulk indexer has sent them yet.
+func (s *ElasticsearchSink) clearReleaseEntries() {
+	if len(s.releaseArticles) == 0 {
+		return
+	}
+	if err := DeleteReleaseEntries(s.config, s.releaseArticles, s.releaseEntries); err != nil {
+		fmt.Printf("⚠ Could not clear old release entries of %d articles: %v\n", len(s.releaseArticles), err)
+	}
+	s.releaseArticles, s.releaseEntries = nil, nil
+}
+
+// ReleaseEntries is how many release entries made it into the releases index
+func (s *ElasticsearchSink) ReleaseEntries() int { return s.indexer.IndexedIn(s.config.ReleasesIndex) }
+
+// DeleteReleaseEntries removes every release entry parsed from the given articles, except the
+// entries with the IDs in keep
+func DeleteReleaseEntries(config ElasticsearchConfig, articleIDs, keep []string) error {
+	clauses := map[string]interface{}{
+		"filter": map[string]interface{}{
+			"terms": map[string]interface{}{"article_id": articleIDs},
+		},
+	}
+	if len(keep) > 0 {
+		clauses["must_not"] = map[string]interface{}{
+			"ids": map[string]interface{}{"values": keep},
+		}
+	}
+	query, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{"bool": clauses},
+	})
+	if err != nil {
+		return err
+	}
+
+	deleteURL := fmt.Sprintf("%s/%s/_delete_by_query?conflicts=proceed", config.URL, config.ReleasesIndex)
+	req, err := NewRequest(config, "POST", deleteURL, bytes.NewReader(query))
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// A missing index just means there is nothing to delete yet
+	if resp.StatusCode == 404 || (resp.StatusCode >= 200 && resp.StatusCode < 300) {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("delete by query returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
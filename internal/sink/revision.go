@@ -0,0 +1,353 @@
+package sink
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// revisionMappingProperties is the mapping of the revisions index. The diff is only stored,
+// the snapshot text stays searchable so old wording can still be found.
+const revisionMappingProperties = `{
+				"id": {"type": "keyword"},
+				"article_id": {"type": "keyword"},
+				"revision": {"type": "integer"},
+				"title": {"type": "text"},
+				"url": {"type": "keyword"},
+				"text": {"type": "text"},
+				"text_hash": {"type": "keyword"},
+				"previous_title": {"type": "text"},
+				"diff": {"type": "text", "index": false},
+				"lines_added": {"type": "integer"},
+				"lines_removed": {"type": "integer"},
+				"updated_at": {"type": "date"},
+				"captured_at": {"type": "date"}
+			}`
+
+// writeRevision snapshots the article when its text or title differs from the latest revision.
+// Full crawls re-write unchanged articles, so the comparison is against the hash of the stored
+// snapshot, which the RevisionLookup remembers; the index is only asked when that differs or is
+// unknown.
+func (s *ElasticsearchSink) writeRevision(article *Article) error {
+	hash := revisionHash(article)
+	var number int
+	var latestHash string
+	if s.revisions != nil {
+		number, latestHash = s.revisions.Revision(article.URL, article.ID)
+	}
+	if number > 0 && latestHash == hash {
+		article.Revision, article.RevisionHash = number, hash
+		return nil
+	}
+
+	var latest *Revision
+	var err error
+	if number > 0 {
+		latest, err = getRevision(s.config, fmt.Sprintf("%s-%d", article.ID, number))
+	}
+	if err == nil && latest == nil {
+		latest, err = latestRevision(s.config, article.ID)
+	}
+	if err != nil {
+		// Guessing the revision number could overwrite history, fail the article so it is retried
+		return fmt.Errorf("could not look up the revision history: %v", err)
+	}
+	if latest != nil && latest.TextHash == hash {
+		article.Revision, article.RevisionHash = latest.Revision, hash
+		return nil
+	}
+
+	revision := Revision{
+		ArticleID:  article.ID,
+		Revision:   1,
+		Title:      article.Title,
+		URL:        article.URL,
+		Text:       article.BodyText,
+		TextHash:   hash,
+		UpdatedAt:  article.UpdatedAt,
+		CapturedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	if latest != nil {
+		revision.Revision = latest.Revision + 1
+		if latest.Title != article.Title {
+			revision.PreviousTitle = latest.Title
+		}
+		ops := diffLines(strings.Split(latest.Text, "\n"), strings.Split(article.BodyText, "\n"))
+		revision.Diff = unifiedDiff(ops, 3)
+		for _, op := range ops {
+			switch op.kind {
+			case '+':
+				revision.LinesAdded++
+			case '-':
+				revision.LinesRemoved++
+			}
+		}
+		fmt.Printf("🕘 %s changed: +%d -%d lines (revision %d)\n", article.Title, revision.LinesAdded, revision.LinesRemoved, revision.Revision)
+	}
+	revision.ID = fmt.Sprintf("%s-%d", article.ID, revision.Revision)
+	article.Revision, article.RevisionHash = revision.Revision, hash
+
+	jsonData, err := json.Marshal(revision)
+	if err != nil {
+		return fmt.Errorf("failed to marshal revision: %v", err)
+	}
+	return s.indexer.Add(BulkItem{
+		Action: "index",
+		Index:  s.config.RevisionsIndex,
+		ID:     revision.ID,
+		URL:    article.URL,
+		Title:  fmt.Sprintf("%s (revision %d)", article.Title, revision.Revision),
+		Source: jsonData,
+	})
+}
+
+// Revisions is how many revisions made it into the revisions index
+func (s *ElasticsearchSink) Revisions() int { return s.indexer.IndexedIn(s.config.RevisionsIndex) }
+
+// getRevision reads one stored revision by ID, or nil when it doesn't exist
+func getRevision(config ElasticsearchConfig, revisionID string) (*Revision, error) {
+	getURL := fmt.Sprintf("%s/%s/_doc/%s", config.URL, config.RevisionsIndex, url.PathEscape(revisionID))
+	req, err := NewRequest(config, "GET", getURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return nil, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("get returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Found  bool     `json:"found"`
+		Source Revision `json:"_source"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode revision %s: %v", revisionID, err)
+	}
+	if !result.Found {
+		return nil, nil
+	}
+	return &result.Source, nil
+}
+
+// latestRevision returns the newest stored revision of an article, or nil when it has none yet
+func latestRevision(config ElasticsearchConfig, articleID string) (*Revision, error) {
+	query, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"term": map[string]interface{}{"article_id": articleID},
+		},
+		"sort": []map[string]interface{}{{"revision": "desc"}},
+		"size": 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	searchURL := fmt.Sprintf("%s/%s/_search", config.URL, config.RevisionsIndex)
+	req, err := NewRequest(config, "POST", searchURL, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// A missing index just means nothing has been recorded yet
+	if resp.StatusCode == 404 {
+		return nil, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("search returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source Revision `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode revision search: %v", err)
+	}
+	if len(result.Hits.Hits) == 0 {
+		return nil, nil
+	}
+	return &result.Hits.Hits[0].Source, nil
+}
+
+// revisionHash covers what a revision snapshots: markup-only changes don't make a new revision
+func revisionHash(article *Article) string {
+	sum := sha256.Sum256([]byte(article.Title + "\x00" + article.BodyText))
+	return hex.EncodeToString(sum[:])
+}
+
+// diffOp is one line of a line diff: kept (' '), removed ('-') or added ('+')
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// maxDiffCells bounds the LCS table of diffLines. A changed block bigger than that is shown as
+// removed and re-added as a whole.
+const maxDiffCells = 4000000
+
+// diffLines computes a line diff from a to b. The common head and tail are matched first so
+// the LCS table only covers the part that changed.
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+
+	oldLines, newLines := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(oldLines)*len(newLines) > maxDiffCells {
+		for _, line := range oldLines {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range newLines {
+			ops = append(ops, diffOp{'+', line})
+		}
+	} else {
+		// lcs[i][j] is the length of the longest common subsequence of oldLines[i:] and newLines[j:]
+		lcs := make([][]int, len(oldLines)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(newLines)+1)
+		}
+		for i := len(oldLines) - 1; i >= 0; i-- {
+			for j := len(newLines) - 1; j >= 0; j-- {
+				if oldLines[i] == newLines[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < len(oldLines) && j < len(newLines) {
+			switch {
+			case oldLines[i] == newLines[j]:
+				ops = append(ops, diffOp{' ', oldLines[i]})
+				i++
+				j++
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				ops = append(ops, diffOp{'-', oldLines[i]})
+				i++
+			default:
+				ops = append(ops, diffOp{'+', newLines[j]})
+				j++
+			}
+		}
+		for ; i < len(oldLines); i++ {
+			ops = append(ops, diffOp{'-', oldLines[i]})
+		}
+		for ; j < len(newLines); j++ {
+			ops = append(ops, diffOp{'+', newLines[j]})
+		}
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// unifiedDiff renders a line diff as unified diff hunks with the given lines of context.
+// Changes closer than twice the context share a hunk.
+func unifiedDiff(ops []diffOp, context int) string {
+	// Line numbers of each op in the old and the new text
+	oldLine, newLine := make([]int, len(ops)), make([]int, len(ops))
+	o, n := 1, 1
+	for k, op := range ops {
+		oldLine[k], newLine[k] = o, n
+		if op.kind != '+' {
+			o++
+		}
+		if op.kind != '-' {
+			n++
+		}
+	}
+
+	var sb strings.Builder
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			k++
+			continue
+		}
+
+		start, end := max(k-context, 0), k
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*context {
+				end = min(end+context, len(ops))
+				break
+			}
+			end = run
+		}
+
+		oldCount, newCount := 0, 0
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		// An empty side is numbered after the line it follows, as diff -u does
+		oldStart, newStart := oldLine[start], newLine[start]
+		if oldCount == 0 {
+			oldStart--
+		}
+		if newCount == 0 {
+			newStart--
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, op := range ops[start:end] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.line)
+			sb.WriteByte('\n')
+		}
+		k = end
+	}
+	return sb.String()
+}
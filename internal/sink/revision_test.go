@@ -0,0 +1,179 @@
+package sink
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string // one op per line: kind then text
+	}{
+		{"unchanged", "a\nb", "a\nb", " a| b"},
+		{"changed line", "a\nb\nc", "a\nx\nc", " a|-b|+x| c"},
+		{"added at the end", "a", "a\nb", " a|+b"},
+		{"removed at the start", "a\nb", "b", "-a| b"},
+		{"from an empty line", "", "x", "-|+x"},
+		{"moved line", "a\nb\nc", "b\nc\na", "-a| b| c|+a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, op := range diffLines(strings.Split(tt.old, "\n"), strings.Split(tt.new, "\n")) {
+				got = append(got, string(op.kind)+op.line)
+			}
+			if strings.Join(got, "|") != tt.want {
+				t.Errorf("diffLines(%q, %q) = %q, want %q", tt.old, tt.new, strings.Join(got, "|"), tt.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	lines := func(replace map[int]string) []string {
+		var out []string
+		for i := 1; i <= 10; i++ {
+			if line, ok := replace[i]; ok {
+				out = append(out, line)
+			} else {
+				out = append(out, strconv.Itoa(i))
+			}
+		}
+		return out
+	}
+
+	tests := []struct {
+		name     string
+		old, new []string
+		context  int
+		want     string
+	}{
+		{"no change", lines(nil), lines(nil), 3, ""},
+		{"one hunk", lines(nil), lines(map[int]string{5: "five"}), 1, "@@ -4,3 +4,3 @@\n 4\n-5\n+five\n 6\n"},
+		{"close changes share a hunk", lines(nil), lines(map[int]string{2: "two", 4: "four"}), 1, "@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n-4\n+four\n 5\n"},
+		{"distant changes", lines(nil), lines(map[int]string{2: "two", 9: "nine"}), 1, "@@ -1,3 +1,3 @@\n 1\n-2\n+two\n 3\n@@ -8,3 +8,3 @@\n 8\n-9\n+nine\n 10\n"},
+		{"addition", []string{"a"}, []string{"a", "b"}, 3, "@@ -1,1 +1,2 @@\n a\n+b\n"},
+		{"into an empty text", nil, []string{"x"}, 3, "@@ -0,0 +1,1 @@\n+x\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff(diffLines(tt.old, tt.new), tt.context); got != tt.want {
+				t.Errorf("unifiedDiff\n got: %q\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// knownRevisions stands in for the crawl state's memory of the latest revision of each URL
+type knownRevisions map[string]Revision
+
+func (k knownRevisions) Revision(articleURL, documentID string) (int, string) {
+	return k[articleURL].Revision, k[articleURL].TextHash
+}
+
+func TestElasticsearchSinkRevisions(t *testing.T) {
+	const articleURL = "https://help.example.com/hc/en-us/articles/100"
+	article := func() *Article {
+		return &Article{ID: "100", Title: "Call queues", URL: articleURL, BodyText: "a\nc"}
+	}
+	unchangedHash := revisionHash(article())
+
+	tests := []struct {
+		name     string
+		known    knownRevisions
+		stored   map[string]string // documents of the revisions index by path
+		want     *Revision         // the revision written, nil for none
+		wantNo   int               // the revision the article ends up at
+		lookedUp bool              // whether the revisions index was asked
+	}{
+		{
+			name:     "first snapshot",
+			want:     &Revision{ID: "100-1", ArticleID: "100", Revision: 1, Title: "Call queues", URL: articleURL, Text: "a\nc", TextHash: unchangedHash},
+			wantNo:   1,
+			lookedUp: true,
+		},
+		{
+			name:   "unchanged since the state's revision",
+			known:  knownRevisions{articleURL: {Revision: 3, TextHash: unchangedHash}},
+			wantNo: 3,
+		},
+		{
+			name:  "changed text and title",
+			known: knownRevisions{articleURL: {Revision: 1, TextHash: "old"}},
+			stored: map[string]string{
+				"/revisions/_doc/100-1": `{"found": true, "_source": {"id": "100-1", "article_id": "100", "revision": 1, "title": "Queues", "text": "a\nb", "text_hash": "old"}}`,
+			},
+			want: &Revision{
+				ID: "100-2", ArticleID: "100", Revision: 2, Title: "Call queues", URL: articleURL, Text: "a\nc", TextHash: unchangedHash,
+				PreviousTitle: "Queues", Diff: "@@ -1,2 +1,2 @@\n a\n-b\n+c\n", LinesAdded: 1, LinesRemoved: 1,
+			},
+			wantNo:   2,
+			lookedUp: true,
+		},
+		{
+			name:  "stored revision has the same text",
+			known: knownRevisions{articleURL: {Revision: 4, TextHash: "unknown"}},
+			stored: map[string]string{
+				"/revisions/_doc/100-4": `{"found": true, "_source": {"id": "100-4", "revision": 4, "text_hash": "` + unchangedHash + `"}}`,
+			},
+			wantNo:   4,
+			lookedUp: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := newElasticsearchServer(t)
+			for path, body := range tt.stored {
+				es.documents[path] = body
+			}
+			sink := NewElasticsearchSink(ElasticsearchConfig{URL: es.URL, Index: "articles", RevisionsIndex: "revisions"}, tt.known, nil)
+			if err := sink.Open(); err != nil {
+				t.Fatal(err)
+			}
+			written := article()
+			if err := sink.Write(written); err != nil {
+				t.Fatal(err)
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if written.Revision != tt.wantNo || written.RevisionHash != unchangedHash {
+				t.Errorf("article at revision %d (%s), want %d (%s)", written.Revision, written.RevisionHash, tt.wantNo, unchangedHash)
+			}
+			lookedUp := slices.ContainsFunc(es.requests, func(request string) bool {
+				return strings.Contains(request, "/revisions/_")
+			})
+			if lookedUp != tt.lookedUp {
+				t.Errorf("revisions index asked = %v, want %v: %v", lookedUp, tt.lookedUp, es.requests)
+			}
+
+			revisions := es.indexed("revisions")
+			if tt.want == nil {
+				if len(revisions) > 0 {
+					t.Errorf("wrote %d revisions, want none", len(revisions))
+				}
+				return
+			}
+			if len(revisions) != 1 {
+				t.Fatalf("wrote %d revisions, want 1", len(revisions))
+			}
+			var got Revision
+			if err := json.Unmarshal(revisions[0].Source, &got); err != nil {
+				t.Fatal(err)
+			}
+			if revisions[0].ID != tt.want.ID {
+				t.Errorf("revision written as %s, want %s", revisions[0].ID, tt.want.ID)
+			}
+			got.CapturedAt = ""
+			if got != *tt.want {
+				t.Errorf("revision\n got: %+v\nwant: %+v", got, *tt.want)
+			}
+		})
+	}
+}
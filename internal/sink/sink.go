@@ -0,0 +1,63 @@
+// Package sink holds the crawled article model and the destinations articles are written to:
+// Elasticsearch through the bulk API, Azure Cognitive Search, a JSONL file and stdout.
+package sink
+
+import "fmt"
+
+// Sink is a destination for crawled articles. The crawler opens every configured sink before
+// crawling, writes each article to all of them and flushes/closes them at the end of the run.
+type Sink interface {
+	Name() string
+	Open() error
+	Write(article *Article) error
+	Flush() error
+	Close() error
+}
+
+// Reporter is implemented by sinks that can report per-document failures
+type Reporter interface {
+	Written() int
+	Failures() []Failure
+}
+
+// Failure is a document a sink rejected, even after retries
+type Failure struct {
+	Sink   string
+	ID     string
+	URL    string
+	Title  string
+	Status int
+	Reason string
+}
+
+// OpenAll opens every sink and drops the ones that fail so one broken destination
+// doesn't stop the crawl from reaching the others
+func OpenAll(sinks []Sink) []Sink {
+	var opened []Sink
+	for _, sink := range sinks {
+		if err := sink.Open(); err != nil {
+			fmt.Printf("⚠ Failed to open %s sink, skipping it: %v\n", sink.Name(), err)
+			continue
+		}
+		fmt.Printf("📤 Writing articles to %s\n", sink.Name())
+		opened = append(opened, sink)
+	}
+	return opened
+}
+
+// CloseAll flushes and closes every sink and collects the documents they failed to write
+func CloseAll(sinks []Sink) []Failure {
+	var failures []Failure
+	for _, sink := range sinks {
+		if err := sink.Flush(); err != nil {
+			fmt.Printf("⚠ Final flush of %s sink failed: %v\n", sink.Name(), err)
+		}
+		if err := sink.Close(); err != nil {
+			fmt.Printf("⚠ Failed to close %s sink: %v\n", sink.Name(), err)
+		}
+		if reporter, ok := sink.(Reporter); ok {
+			failures = append(failures, reporter.Failures()...)
+		}
+	}
+	return failures
+}
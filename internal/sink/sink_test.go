@@ -0,0 +1,96 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// brokenSink can't be opened, like a destination that is misconfigured or down
+type brokenSink struct{ StdoutSink }
+
+func (s *brokenSink) Name() string { return "broken" }
+func (s *brokenSink) Open() error  { return errors.New("no credentials") }
+
+func TestOpenAllSkipsBrokenSinks(t *testing.T) {
+	var out bytes.Buffer
+	stdout := NewStdoutSink(&out)
+	opened := OpenAll([]Sink{&brokenSink{}, stdout})
+	if len(opened) != 1 || opened[0] != Sink(stdout) {
+		t.Fatalf("opened %v, want only the stdout sink", opened)
+	}
+	if failures := CloseAll(opened); len(failures) != 0 {
+		t.Errorf("CloseAll reported %v", failures)
+	}
+}
+
+func TestJSONLSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "articles.jsonl")
+	articles := []*Article{
+		{ID: "1", Title: "Queues", URL: "https://help.example.com/hc/en-us/articles/1", Revision: 2, RevisionHash: "abc"},
+		{ID: "2", Title: "Skills", URL: "https://help.example.com/hc/en-us/articles/2"},
+	}
+	// A second run appends to the file instead of replacing it
+	for run := 0; run < 2; run++ {
+		sink := NewJSONLSink(path)
+		if failures := CloseAll(write(t, []Sink{sink}, articles)); len(failures) != 0 {
+			t.Fatalf("CloseAll reported %v", failures)
+		}
+		if sink.Written() != len(articles) {
+			t.Errorf("Written = %d, want %d", sink.Written(), len(articles))
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var ids []string
+	for _, line := range lines {
+		var article map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &article); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		ids = append(ids, article["id"].(string))
+		if _, ok := article["Revision"]; ok {
+			t.Errorf("the revision the sink compared against was written out: %s", line)
+		}
+	}
+	if want := []string{"1", "2", "1", "2"}; !slices.Equal(ids, want) {
+		t.Errorf("articles written %v, want %v", ids, want)
+	}
+}
+
+func TestStdoutSink(t *testing.T) {
+	var out bytes.Buffer
+	articles := []*Article{{ID: "1", Title: "Queues"}, {ID: "2", Title: "Skills"}}
+	CloseAll(write(t, []Sink{NewStdoutSink(&out)}, articles))
+
+	want := `{"id":"1","title":"Queues","body":"","url":""}` + "\n" + `{"id":"2","title":"Skills","body":"","url":""}` + "\n"
+	if out.String() != want {
+		t.Errorf("stdout sink wrote\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+// write opens the sinks and writes every article to each of them
+func write(t *testing.T, sinks []Sink, articles []*Article) []Sink {
+	t.Helper()
+	opened := OpenAll(sinks)
+	if len(opened) != len(sinks) {
+		t.Fatalf("opened %d of %d sinks", len(opened), len(sinks))
+	}
+	for _, sink := range opened {
+		for _, article := range articles {
+			if err := sink.Write(article); err != nil {
+				t.Fatalf("%s: %v", sink.Name(), err)
+			}
+		}
+	}
+	return opened
+}
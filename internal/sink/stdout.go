@@ -0,0 +1,36 @@
+package sink
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// StdoutSink prints one JSON article per line, handy for piping a crawl into other tools
+type StdoutSink struct {
+	out     io.Writer
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewStdoutSink writes articles to out, normally the process's real stdout
+func NewStdoutSink(out io.Writer) *StdoutSink {
+	return &StdoutSink{out: out}
+}
+
+func (s *StdoutSink) Name() string { return "stdout" }
+
+func (s *StdoutSink) Open() error {
+	s.encoder = json.NewEncoder(s.out)
+	return nil
+}
+
+func (s *StdoutSink) Write(article *Article) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.encoder.Encode(article)
+}
+
+func (s *StdoutSink) Flush() error { return nil }
+
+func (s *StdoutSink) Close() error { return nil }
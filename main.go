@@ -2,14 +2,19 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"os"
 	"regexp"
-	"strings"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gocolly/colly/v2"
+
+	"release-crawler/internal/polite"
 )
 
 type Article struct {
@@ -27,56 +32,85 @@ type ArticleResponse struct {
 }
 
 func main() {
-	releaseNotesURLs := []string{
-		"https://support.talkdesk.com/hc/en-us/sections/200263245-Release-Notes?page=1#articles",
-		"https://support.talkdesk.com/hc/en-us/sections/200263245-Release-Notes?page=2#articles",
+	// The first listing page; the following ones are visited until a page has no new articles
+	releaseNotesURL := "https://support.talkdesk.com/hc/en-us/sections/200263245-Release-Notes?page=1#articles"
+
+	// The crawler's robots.txt policy and per-host rate limiter, so listing pages and article JSON
+	// are fetched with the same manners as comprehensive-crawler.go: rules and Crawl-delay for
+	// ROBOTS_USER_AGENT, which is also the user agent sent
+	userAgent := os.Getenv("ROBOTS_USER_AGENT")
+	if userAgent == "" {
+		userAgent = "release-crawler"
 	}
+	robots := polite.NewRobotsPolicy(true, userAgent, 30*time.Second)
+	limiter := polite.NewRateLimiter(1, 0.1, 5*time.Minute, robots)
 
 	c := colly.NewCollector()
 
-	// Set a realistic User-Agent to avoid being blocked
-	c.UserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
-
-	// Add some delays to be respectful
-	c.Limit(&colly.LimitRule{
-		DomainGlob:  "*",
-		Parallelism: 1,
-		Delay:       1 * time.Second,
-	})
+	// robots.txt is checked by the shared policy in OnRequest; colly's own checker knows nothing
+	// of Crawl-delay
+	c.IgnoreRobotsTxt = true
+	c.UserAgent = robots.UserAgent()
 
-	// Store article URLs
+	// Store article URLs, with a set to skip the ones already found
 	var articleURLs []string
+	seen := make(map[string]bool)
+	newOnPage := 0
 
 	// Extract article links from release notes pages
 	c.OnHTML("a[href*='/articles/']", func(e *colly.HTMLElement) {
-		href := e.Attr("href")
-		// Convert relative URLs to absolute
-		if strings.HasPrefix(href, "/") {
-			href = "https://support.talkdesk.com" + href
+		// Resolve relative URLs against the listing page, whichever help center it is on
+		href := e.Request.AbsoluteURL(e.Attr("href"))
+		if href == "" {
+			return
 		}
 
 		// Only collect article URLs, avoid duplicates
-		for _, existing := range articleURLs {
-			if existing == href {
-				return
-			}
+		if seen[href] {
+			return
 		}
+		seen[href] = true
+		newOnPage++
 		articleURLs = append(articleURLs, href)
 		fmt.Printf("Found article: %s\n", href)
 	})
 
 	c.OnRequest(func(r *colly.Request) {
+		if allowed, rule := robots.Allowed(r.URL.String()); !allowed {
+			fmt.Printf("🤖 Skipping %s: blocked by robots.txt rule %s\n", r.URL, rule)
+			r.Abort()
+			return
+		}
+		limiter.Wait(r.URL.String())
+		newOnPage = 0
 		fmt.Printf("Visiting: %s\n", r.URL)
 	})
 
+	c.OnResponse(func(r *colly.Response) {
+		limiter.Report(r.Request.URL.String(), nil)
+	})
+
+	// Follow the pagination while pages keep listing new articles
+	c.OnScraped(func(r *colly.Response) {
+		if newOnPage == 0 {
+			return
+		}
+		if next := nextPageURL(r.Request.URL); next != "" {
+			r.Request.Visit(next)
+		}
+	})
+
 	c.OnError(func(r *colly.Response, err error) {
+		if r.StatusCode != 0 {
+			err = &polite.HTTPStatusError{URL: r.Request.URL.String(), StatusCode: r.StatusCode, Message: err.Error(),
+				RetryAfter: polite.ParseRetryAfter(r.Headers.Get("Retry-After"))}
+		}
+		limiter.Report(r.Request.URL.String(), err)
 		fmt.Printf("Error: %s\n", err.Error())
 	})
 
 	// Visit release notes pages to collect article URLs
-	for _, url := range releaseNotesURLs {
-		c.Visit(url)
-	}
+	c.Visit(releaseNotesURL)
 
 	fmt.Printf("\nFound %d articles. Fetching JSON data...\n", len(articleURLs))
 
@@ -96,10 +130,7 @@ func main() {
 			semaphore <- struct{}{}
 			defer func() { <-semaphore }()
 
-			// Add delay between requests
-			time.Sleep(500 * time.Millisecond)
-
-			jsonData := getArticleJSON(url)
+			jsonData := getArticleJSON(url, robots, limiter)
 			if jsonData != nil {
 				mu.Lock()
 				articles = append(articles, *jsonData)
@@ -113,7 +144,21 @@ func main() {
 	fmt.Printf("\nSuccessfully fetched %d articles\n", len(articles))
 }
 
-func getArticleJSON(articleURL string) *ArticleResponse {
+// nextPageURL returns the listing page after a ?page=N page, "" when the URL has no page number
+func nextPageURL(pageURL *url.URL) string {
+	query := pageURL.Query()
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		return ""
+	}
+	query.Set("page", strconv.Itoa(page+1))
+	next := *pageURL
+	next.RawQuery = query.Encode()
+	next.Fragment = ""
+	return next.String()
+}
+
+func getArticleJSON(articleURL string, robots *polite.RobotsPolicy, limiter *polite.RateLimiter) *ArticleResponse {
 	// Extract article ID from URL
 	re := regexp.MustCompile(`/articles/(\d+)`)
 	matches := re.FindStringSubmatch(articleURL)
@@ -124,10 +169,15 @@ func getArticleJSON(articleURL string) *ArticleResponse {
 
 	articleID := matches[1]
 
-	// Zendesk API endpoint for article JSON
-	jsonURL := fmt.Sprintf("https://support.talkdesk.com/api/v2/help_center/articles/%s.json", articleID)
+	// Zendesk API endpoint for article JSON, on the help center the article was found on
+	u, err := url.Parse(articleURL)
+	if err != nil {
+		fmt.Printf("Invalid article URL %s: %v\n", articleURL, err)
+		return nil
+	}
+	jsonURL := fmt.Sprintf("%s://%s/api/v2/help_center/articles/%s.json", u.Scheme, u.Host, articleID)
 
-	// Create HTTP client with realistic headers
+	// Create HTTP client
 	client := &http.Client{Timeout: 10 * time.Second}
 	req, err := http.NewRequest("GET", jsonURL, nil)
 	if err != nil {
@@ -135,23 +185,22 @@ func getArticleJSON(articleURL string) *ArticleResponse {
 		return nil
 	}
 
-	// Set realistic headers
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
 	req.Header.Set("Accept", "application/json, text/plain, */*")
 	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
 
-	resp, err := client.Do(req)
+	// Checked against robots.txt and paced by the host's rate, sent as the policy's user agent
+	resp, err := polite.Do(client, robots, limiter, req)
+	var blocked *polite.RobotsBlockedError
+	if errors.As(err, &blocked) {
+		fmt.Printf("🤖 Skipping %s: blocked by robots.txt rule %s\n", jsonURL, blocked.Rule)
+		return nil
+	}
 	if err != nil {
 		fmt.Printf("Error fetching JSON for article %s: %v\n", articleID, err)
 		return nil
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		fmt.Printf("HTTP %d for article %s JSON\n", resp.StatusCode, articleID)
-		return nil
-	}
-
 	var articleData ArticleResponse
 	if err := json.NewDecoder(resp.Body).Decode(&articleData); err != nil {
 		fmt.Printf("Error decoding JSON for article %s: %v\n", articleID, err)
@@ -2,17 +2,46 @@ package main
 
 import (
 	"bytes"
-	"encoding/json"
+	_ "embed"
+	"errors"
 	"fmt"
-	"net/http"
+	"io"
 	"os"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/gocolly/colly/v2"
+	"gopkg.in/yaml.v3"
 )
 
+// The crawler's site profile, so this test extracts articles the same way it does
+//
+//go:embed profiles/zendesk.yaml
+var defaultSiteProfileYAML []byte
+
+// SiteProfile has every key of the crawler's site profile, so a profile the crawler accepts
+// decodes here too and a misspelled key fails in both. This test only uses the extraction rules.
+type SiteProfile struct {
+	Name           string   `yaml:"name"`
+	Include        []string `yaml:"include"`
+	Exclude        []string `yaml:"exclude"`
+	IDPattern      string   `yaml:"id_pattern"`
+	Title          []string `yaml:"title"`
+	TitleSkipLines []string `yaml:"title_skip_lines"`
+	IgnoreTitles   []string `yaml:"ignore_titles"`
+	Body           []string `yaml:"body"`
+	Strip          []string `yaml:"strip"`
+	Breadcrumbs    []string `yaml:"breadcrumbs"`
+	Dates          []string `yaml:"dates"`
+	DateText       []string `yaml:"date_text"`
+	LoginURLs      []string `yaml:"login_urls"`
+	LoginForm      []string `yaml:"login_form"`
+
+	idPattern *regexp.Regexp
+}
+
 type Article struct {
 	ID        string `json:"id"`
 	Title     string `json:"title"`
@@ -22,29 +51,36 @@ type Article struct {
 	UpdatedAt string `json:"updated_at"`
 }
 
-type AzureDocument struct {
-	SearchAction string `json:"@search.action"`
-	ID           string `json:"id"`
-	Title        string `json:"title"`
-	Body         string `json:"body"`
-	URL          string `json:"url"`
-	CreatedAt    string `json:"created_at"`
-	UpdatedAt    string `json:"updated_at"`
-	IndexedAt    string `json:"indexed_at"`
-}
-
-type AzureBatchRequest struct {
-	Value []AzureDocument `json:"value"`
-}
-
 func main() {
 	// Test with the Polly article
 	pollyURL := "https://support.talkdesk.com/hc/en-us/articles/7411707908635-Talkdesk-Studio-Text-to-Speech-Powered-by-Amazon-Polly"
 	
 	fmt.Printf("🔍 Testing with Polly article: %s\n", pollyURL)
 	
+	// SITE_PROFILE overrides the built-in profile like it does for the crawler
+	var profile SiteProfile
+	if err := decodeSiteProfile(defaultSiteProfileYAML, &profile); err != nil {
+		fmt.Printf("❌ Error reading built-in site profile: %v\n", err)
+		return
+	}
+	if path := os.Getenv("SITE_PROFILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = decodeSiteProfile(data, &profile)
+		}
+		if err != nil {
+			fmt.Printf("❌ Error reading site profile %s: %v\n", path, err)
+			return
+		}
+	}
+	var err error
+	if profile.idPattern, err = regexp.Compile(profile.IDPattern); err != nil {
+		fmt.Printf("❌ Error in site profile id_pattern: %v\n", err)
+		return
+	}
+
 	// Scrape the article
-	article, err := scrapeFullArticle(pollyURL, 15*time.Second)
+	article, err := scrapeFullArticle(pollyURL, profile, 15*time.Second)
 	if err != nil {
 		fmt.Printf("❌ Error scraping article: %v\n", err)
 		return
@@ -54,27 +90,22 @@ func main() {
 	fmt.Printf("📝 Content length: %d characters\n", len(article.Body))
 	fmt.Printf("🔗 URL: %s\n", article.URL)
 	
-	// Index to Azure
-	azureConfig := struct {
-		ServiceName string
-		ApiKey      string
-		IndexName   string
-	}{
-		ServiceName: os.Getenv("AZURE_SEARCH_SERVICE"),
-		ApiKey:      os.Getenv("AZURE_SEARCH_KEY"),
-		IndexName:   os.Getenv("AZURE_SEARCH_INDEX"),
-	}
-	
-	if err := indexToAzure(article, azureConfig); err != nil {
-		fmt.Printf("❌ Error indexing to Azure: %v\n", err)
-		return
+	// Indexing is the crawler's job now: its azure sink sends articles to Azure Cognitive Search
+	fmt.Printf("🔍 To index it, crawl with CRAWL_SINKS=azure (see Output Sinks in the README)\n")
+}
+
+// decodeSiteProfile reads a YAML or JSON profile over the values already in profile, rejecting
+// unknown keys like the crawler does
+func decodeSiteProfile(data []byte, profile *SiteProfile) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(profile); err != nil && !errors.Is(err, io.EOF) {
+		return err
 	}
-	
-	fmt.Printf("🔍 Successfully indexed to Azure Search!\n")
-	fmt.Printf("🌐 Try searching for 'polly' at: http://talkdesk-search-demo.eastus.azurecontainer.io:8080\n")
+	return nil
 }
 
-func scrapeFullArticle(articleURL string, timeout time.Duration) (*Article, error) {
+func scrapeFullArticle(articleURL string, profile SiteProfile, timeout time.Duration) (*Article, error) {
 	c := colly.NewCollector()
 	c.UserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
 	c.SetRequestTimeout(timeout)
@@ -85,30 +116,42 @@ func scrapeFullArticle(articleURL string, timeout time.Duration) (*Article, erro
 	article.URL = articleURL
 
 	// Extract article ID from URL
-	re := regexp.MustCompile(`/articles/(\d+)`)
-	matches := re.FindStringSubmatch(articleURL)
+	matches := profile.idPattern.FindStringSubmatch(articleURL)
 	if len(matches) >= 2 {
 		article.ID = matches[1]
 	}
 
-	// Extract title
-	c.OnHTML("h1, .article-title, [data-testid='article-title']", func(e *colly.HTMLElement) {
-		if article.Title == "" {
-			article.Title = strings.TrimSpace(e.Text)
-		}
-	})
+	// Extract title, skipping metadata lines and page chrome
+	for _, selector := range profile.Title {
+		c.OnHTML(selector, func(e *colly.HTMLElement) {
+			for _, line := range strings.Split(e.Text, "\n") {
+				line = strings.TrimSpace(line)
+				if article.Title != "" || line == "" || slices.Contains(profile.IgnoreTitles, line) {
+					continue
+				}
+				if !slices.ContainsFunc(profile.TitleSkipLines, func(skip string) bool { return strings.Contains(line, skip) }) {
+					article.Title = line
+				}
+			}
+		})
+	}
 
 	// Extract the main article content with full HTML
-	c.OnHTML(".article-body, .article-content, [data-testid='article-body'], .article__body, .article-body-container, .article-content-body, article, main", func(e *colly.HTMLElement) {
-		if article.Body == "" {
-			if html, err := e.DOM.Html(); err == nil {
-				article.Body = html
+	for _, selector := range profile.Body {
+		c.OnHTML(selector, func(e *colly.HTMLElement) {
+			if article.Body == "" {
+				for _, strip := range profile.Strip {
+					e.DOM.Find(strip).Remove()
+				}
+				if html, err := e.DOM.Html(); err == nil {
+					article.Body = html
+				}
 			}
-		}
-	})
+		})
+	}
 
 	// Extract metadata if available
-	c.OnHTML("time[datetime], .article-created-at, .article-updated-at", func(e *colly.HTMLElement) {
+	c.OnHTML(strings.Join(profile.Dates, ", "), func(e *colly.HTMLElement) {
 		datetime := e.Attr("datetime")
 		if datetime != "" {
 			if article.CreatedAt == "" {
@@ -131,7 +174,7 @@ func scrapeFullArticle(articleURL string, timeout time.Duration) (*Article, erro
 		return nil, fmt.Errorf("scraping error: %v", scrapeErr)
 	}
 
-	if article.Title == "" || article.Title == "How can we help?" {
+	if article.Title == "" {
 		return nil, fmt.Errorf("no meaningful title found on page")
 	}
 
@@ -149,55 +192,3 @@ func scrapeFullArticle(articleURL string, timeout time.Duration) (*Article, erro
 
 	return &article, nil
 }
-
-func indexToAzure(article *Article, config struct{ ServiceName, ApiKey, IndexName string }) error {
-	// Transform article data for Azure Cognitive Search
-	doc := AzureDocument{
-		SearchAction: "mergeOrUpload",
-		ID:           article.ID,
-		Title:        article.Title,
-		Body:         article.Body,
-		URL:          article.URL,
-		CreatedAt:    article.CreatedAt,
-		UpdatedAt:    article.UpdatedAt,
-		IndexedAt:    time.Now().UTC().Format(time.RFC3339),
-	}
-
-	// Create batch request
-	batchRequest := AzureBatchRequest{
-		Value: []AzureDocument{doc},
-	}
-
-	// Convert to JSON
-	jsonData, err := json.Marshal(batchRequest)
-	if err != nil {
-		return fmt.Errorf("failed to marshal document: %v", err)
-	}
-
-	// Create Azure Search request
-	azureURL := fmt.Sprintf("https://%s.search.windows.net/indexes/%s/docs/index?api-version=2021-04-30-Preview",
-		config.ServiceName, config.IndexName)
-	
-	req, err := http.NewRequest("POST", azureURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to create Azure request: %v", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("api-key", config.ApiKey)
-
-	// Send to Azure Cognitive Search with timeout
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to index to Azure: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("Azure indexing failed with status %d", resp.StatusCode)
-	}
-
-	fmt.Printf("🔍 Indexed to Azure Search: %s\n", article.Title)
-	return nil
-}
\ No newline at end of file
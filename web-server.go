@@ -13,16 +13,21 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"release-crawler/internal/search"
+)
+
+// The search programs share the article model and query building in internal/search
+type (
+	Article          = search.Article
+	Passage          = search.Passage
+	DuplicateArticle = search.DuplicateArticle
 )
 
-type Article struct {
-	ID        string `json:"id"`
-	Title     string `json:"title"`
-	Body      string `json:"body"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
-	HTMLURL   string `json:"url"`
-	SectionID int64  `json:"section_id"`
+// FacetBucket is one value of a facet with the number of matching articles
+type FacetBucket struct {
+	Value string
+	Count int
 }
 
 type SearchRequest struct {
@@ -37,10 +42,24 @@ type SearchResponse struct {
 			Value int `json:"value"`
 		} `json:"total"`
 		Hits []struct {
-			Source Article `json:"_source"`
-			Score  float64 `json:"_score"`
+			Source    Article `json:"_source"`
+			Score     float64 `json:"_score"`
+			InnerHits map[string]struct {
+				Hits struct {
+					Hits []struct {
+						Source json.RawMessage `json:"_source"` // a Passage or a DuplicateArticle
+					} `json:"hits"`
+				} `json:"hits"`
+			} `json:"inner_hits"`
 		} `json:"hits"`
 	} `json:"hits"`
+	Aggregations map[string]struct {
+		Buckets []struct {
+			Key      string `json:"key"`
+			DocCount int    `json:"doc_count"`
+		} `json:"buckets"`
+		Value int `json:"value"` // cardinality aggregations
+	} `json:"aggregations"`
 }
 
 type SearchResult struct {
@@ -56,6 +75,11 @@ type SearchResult struct {
 	ResultsPerPage int
 	Suggestions    []string
 	SearchTime     string
+	Section        string
+	Category       string
+	Categories     []FacetBucket // product areas of the matching articles
+	Lang           string        // language asked for with ?lang=, kept in links
+	Source         string        // crawled site asked for with ?source=, kept in links
 }
 
 type AutocompleteResponse struct {
@@ -228,6 +252,48 @@ const htmlTemplate = `<!DOCTYPE html>
             font-size: 14px;
         }
         
+        .facets a {
+            display: inline-block;
+            margin: 2px 4px;
+            padding: 3px 10px;
+            border-radius: 12px;
+            background: #ecf0f1;
+            color: #2c3e50;
+            font-size: 13px;
+            text-decoration: none;
+        }
+        
+        .facets a.active {
+            background: #3498db;
+            color: white;
+        }
+        
+        .article-breadcrumbs {
+            color: #7f8c8d;
+            font-size: 13px;
+            margin-bottom: 6px;
+        }
+
+        .article-passage {
+            font-size: 14px;
+            margin: -6px 0 10px;
+        }
+
+        .article-passage a {
+            color: #16a085;
+            text-decoration: none;
+        }
+
+        .article-duplicates {
+            font-size: 13px;
+            color: #7f8c8d;
+            margin-top: 12px;
+        }
+
+        .article-duplicates a {
+            color: #7f8c8d;
+        }
+        
         .article {
             background: white;
             padding: 25px;
@@ -388,6 +454,10 @@ const htmlTemplate = `<!DOCTYPE html>
                     <input type="text" name="q" id="searchInput" class="search-input" placeholder="Search release notes... (try quotes for exact phrases)" value="{{.Query}}" autofocus autocomplete="off">
                     <div id="autocompleteSuggestions" class="autocomplete-suggestions"></div>
                 </div>
+                {{if .Category}}<input type="hidden" name="category" value="{{.Category}}">{{end}}
+                {{if .Section}}<input type="hidden" name="section" value="{{.Section}}">{{end}}
+                {{if .Lang}}<input type="hidden" name="lang" value="{{.Lang}}">{{end}}
+                {{if .Source}}<input type="hidden" name="source" value="{{.Source}}">{{end}}
                 <button type="submit" class="search-button">Search</button>
                 {{if .SearchTime}}<span class="search-stats">{{.SearchTime}}</span>{{end}}
             </form>
@@ -397,30 +467,43 @@ const htmlTemplate = `<!DOCTYPE html>
             {{if .Articles}}
                 <div class="results-info">
                     <div class="results-count">
-                        Found {{.Total}} results for "{{.Query}}" • Page {{.CurrentPage}} of {{.TotalPages}}
+                        Found {{.Total}} results for "{{.Query}}"{{if .Source}} on {{.Source}}{{end}}{{if .Category}} in {{.Category}}{{end}}{{if .Section}} › {{.Section}}{{end}} • Page {{.CurrentPage}} of {{.TotalPages}}
+                    </div>
+                    {{if .Categories}}
+                    <div class="facets">
+                        {{if or .Category .Section}}<a href="?q={{.Query}}{{if .Lang}}&lang={{.Lang}}{{end}}{{if .Source}}&source={{.Source}}{{end}}">All areas</a>{{end}}
+                        {{range .Categories}}
+                            <a href="?q={{$.Query}}&category={{.Value}}{{if $.Lang}}&lang={{$.Lang}}{{end}}{{if $.Source}}&source={{$.Source}}{{end}}"{{if eq .Value $.Category}} class="active"{{end}}>{{.Value}} ({{.Count}})</a>
+                        {{end}}
                     </div>
+                    {{end}}
                 </div>
                 
                 {{range .Articles}}
                 <div class="article">
+                    {{if .Breadcrumbs}}<div class="article-breadcrumbs">{{join .Breadcrumbs " › "}}</div>{{end}}
                     <h2 class="article-title">
                         <a href="{{.HTMLURL}}" target="_blank">{{.Title}}</a>
                     </h2>
+                    {{if and .BestPassage (ne .BestPassage.URL .HTMLURL)}}<div class="article-passage">↳ <a href="{{.BestPassage.URL}}" target="_blank">{{.BestPassage.Title}}</a></div>{{end}}
                     <div class="article-meta">
-                        <span>📅 Created: {{.CreatedAt}}</span>
-                        <span>🔄 Updated: {{.UpdatedAt}}</span>
+                        <span>📅 Created: {{if .CreatedAt}}{{.CreatedAt}}{{else}}unknown{{end}}</span>
+                        <span>🔄 Updated: {{if .UpdatedAt}}{{.UpdatedAt}}{{else}}unknown{{end}}</span>
+                        {{if .DatesUnreliable}}<span title="The page shows no publish or update date">⚠ Date not confirmed</span>{{end}}
                         <span>🆔 ID: {{.ID}}</span>
+                        {{if .Source}}<span>🌐 {{.Source}}</span>{{end}}
                     </div>
                     <div class="article-body">
-                        {{.Body | truncateHTML}}
+                        {{.BodyText | snippet}}
                     </div>
+                    {{if .Duplicates}}<div class="article-duplicates">Similar articles: {{range $i, $d := .Duplicates}}{{if $i}}, {{end}}<a href="{{$d.URL}}" target="_blank">{{$d.Title}}</a>{{end}}</div>{{end}}
                 </div>
                 {{end}}
                 
                 {{if gt .TotalPages 1}}
                 <div class="pagination">
                     {{if .HasPrev}}
-                        <a href="?q={{.Query}}&page={{.PrevPage}}">&larr; Previous</a>
+                        <a href="?q={{.Query}}&category={{.Category}}&section={{.Section}}{{if .Lang}}&lang={{.Lang}}{{end}}{{if .Source}}&source={{.Source}}{{end}}&page={{.PrevPage}}">&larr; Previous</a>
                     {{else}}
                         <span class="disabled">&larr; Previous</span>
                     {{end}}
@@ -429,7 +512,7 @@ const htmlTemplate = `<!DOCTYPE html>
                     <span>of {{.TotalPages}}</span>
                     
                     {{if .HasNext}}
-                        <a href="?q={{.Query}}&page={{.NextPage}}">Next &rarr;</a>
+                        <a href="?q={{.Query}}&category={{.Category}}&section={{.Section}}{{if .Lang}}&lang={{.Lang}}{{end}}{{if .Source}}&source={{.Source}}{{end}}&page={{.NextPage}}">Next &rarr;</a>
                     {{else}}
                         <span class="disabled">Next &rarr;</span>
                     {{end}}
@@ -608,14 +691,28 @@ func searchHandler(w http.ResponseWriter, r *http.Request) {
 	resultsPerPage := 10
 	from := (page - 1) * resultsPerPage
 	
+	filters := search.Filters{
+		Section:  strings.TrimSpace(r.URL.Query().Get("section")),
+		Category: strings.TrimSpace(r.URL.Query().Get("category")),
+		Source:   strings.TrimSpace(r.URL.Query().Get("source")),
+		Language: strings.TrimSpace(r.URL.Query().Get("lang")),
+	}
+
 	result := SearchResult{
 		Query:          query,
 		CurrentPage:    page,
 		ResultsPerPage: resultsPerPage,
+		Section:        filters.Section,
+		Category:       filters.Category,
+		Lang:           filters.Language,
+		Source:         filters.Source,
+	}
+	if filters.Language == "" {
+		filters.Language = "en"
 	}
 	
 	if query != "" {
-		articles, total, err := searchElasticsearch(query, from, resultsPerPage)
+		articles, total, categories, err := searchElasticsearch(query, from, resultsPerPage, filters)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Search error: %v", err), http.StatusInternalServerError)
 			return
@@ -623,6 +720,7 @@ func searchHandler(w http.ResponseWriter, r *http.Request) {
 		
 		result.Articles = articles
 		result.Total = total
+		result.Categories = categories
 		result.TotalPages = (total + resultsPerPage - 1) / resultsPerPage
 		result.HasPrev = page > 1
 		result.HasNext = page < result.TotalPages
@@ -631,7 +729,8 @@ func searchHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	tmpl := template.Must(template.New("search").Funcs(template.FuncMap{
-		"truncateHTML": truncateHTML,
+		"snippet": snippet,
+		"join":    strings.Join,
 	}).Parse(htmlTemplate))
 	
 	w.Header().Set("Content-Type", "text/html")
@@ -650,19 +749,25 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func searchElasticsearch(query string, from, size int) ([]Article, int, error) {
+func searchElasticsearch(query string, from, size int, filters search.Filters) ([]Article, int, []FacetBucket, error) {
 	// Check cache first
-	cacheKey := fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("%s-%d-%d", query, from, size))))
+	cacheKey := fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("%s-%d-%d-%s-%s-%s-%s", query, from, size, filters.Section, filters.Category, filters.Source, filters.Language))))
 	if cachedResult, found := getCachedResult(cacheKey); found {
-		return cachedResult.Articles, cachedResult.Total, nil
+		return cachedResult.Articles, cachedResult.Total, cachedResult.Categories, nil
 	}
 
 	// Build enhanced query with fuzzy search and phrase detection
-	esQuery := buildEnhancedQuery(query, from, size)
+	esQuery := search.Query(query, from, size, filters)
+	esQuery["aggs"] = map[string]interface{}{
+		"categories": map[string]interface{}{
+			"terms": map[string]interface{}{"field": "category_name.keyword", "size": 20},
+		},
+	}
+	search.CollapseDuplicates(esQuery)
 
 	jsonData, err := json.Marshal(esQuery)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 
 	client := &http.Client{Timeout: 10 * time.Second}
@@ -670,28 +775,50 @@ func searchElasticsearch(query string, from, size int) ([]Article, int, error) {
 	indexName := getEnv("ELASTICSEARCH_INDEX", "documentation-articles")
 	resp, err := client.Post(fmt.Sprintf("%s/%s/_search", esURL, indexName), "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 	defer resp.Body.Close()
 
 	var searchResp SearchResponse
 	if err := json.NewDecoder(resp.Body).Decode(&searchResp); err != nil {
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 
 	var articles []Article
 	for _, hit := range searchResp.Hits.Hits {
-		articles = append(articles, hit.Source)
+		article := hit.Source
+		if passages := hit.InnerHits["passages"].Hits.Hits; len(passages) > 0 {
+			var passage Passage
+			if json.Unmarshal(passages[0].Source, &passage) == nil {
+				article.BestPassage = &passage
+			}
+		}
+		for _, duplicate := range hit.InnerHits["duplicates"].Hits.Hits {
+			var other DuplicateArticle
+			if json.Unmarshal(duplicate.Source, &other) == nil && other.ID != article.ID {
+				article.Duplicates = append(article.Duplicates, other)
+			}
+		}
+		articles = append(articles, article)
+	}
+
+	// Results are paged by cluster of near-duplicates, not by article
+	total := searchResp.Aggregations["clusters"].Value
+
+	var categories []FacetBucket
+	for _, bucket := range searchResp.Aggregations["categories"].Buckets {
+		categories = append(categories, FacetBucket{Value: bucket.Key, Count: bucket.DocCount})
 	}
 
 	// Cache the result
 	result := SearchResult{
-		Articles: articles,
-		Total:    searchResp.Hits.Total.Value,
+		Articles:   articles,
+		Total:      total,
+		Categories: categories,
 	}
 	cacheResult(cacheKey, result)
 
-	return articles, searchResp.Hits.Total.Value, nil
+	return articles, total, categories, nil
 }
 
 func oldSearchElasticsearch(query string, from, size int) ([]Article, int, error) {
@@ -760,226 +887,13 @@ func oldSearchElasticsearch(query string, from, size int) ([]Article, int, error
 	return articles, searchResp.Hits.Total.Value, nil
 }
 
-func truncateHTML(text string) template.HTML {
-	// Clean up HTML for better display
-	text = strings.ReplaceAll(text, "&lt;", "<")
-	text = strings.ReplaceAll(text, "&gt;", ">")
-	text = strings.ReplaceAll(text, "&amp;", "&")
-	text = strings.ReplaceAll(text, "&quot;", "\"")
-	text = strings.ReplaceAll(text, "&nbsp;", " ")
-	
-	// Remove common structural HTML tags that create whitespace
-	text = strings.ReplaceAll(text, "<div>", "")
-	text = strings.ReplaceAll(text, "</div>", "\n")
-	text = strings.ReplaceAll(text, "<section>", "")
-	text = strings.ReplaceAll(text, "</section>", "\n")
-	text = strings.ReplaceAll(text, "<nav>", "")
-	text = strings.ReplaceAll(text, "</nav>", "")
-	text = strings.ReplaceAll(text, "<header>", "")
-	text = strings.ReplaceAll(text, "</header>", "\n")
-	text = strings.ReplaceAll(text, "<footer>", "")
-	text = strings.ReplaceAll(text, "</footer>", "")
-	
-	// Convert common HTML tags to readable format
-	text = strings.ReplaceAll(text, "<p>", "")
-	text = strings.ReplaceAll(text, "</p>", "\n\n")
-	text = strings.ReplaceAll(text, "<br>", "\n")
-	text = strings.ReplaceAll(text, "<br/>", "\n")
-	text = strings.ReplaceAll(text, "<br />", "\n")
-	
-	// Handle headings
-	text = strings.ReplaceAll(text, "<h1>", "")
-	text = strings.ReplaceAll(text, "</h1>", "\n\n")
-	text = strings.ReplaceAll(text, "<h2>", "")
-	text = strings.ReplaceAll(text, "</h2>", "\n\n")
-	text = strings.ReplaceAll(text, "<h3>", "")
-	text = strings.ReplaceAll(text, "</h3>", "\n\n")
-	
-	// Handle lists
-	text = strings.ReplaceAll(text, "<ul>", "")
-	text = strings.ReplaceAll(text, "</ul>", "\n")
-	text = strings.ReplaceAll(text, "<ol>", "")
-	text = strings.ReplaceAll(text, "</ol>", "\n")
-	text = strings.ReplaceAll(text, "<li>", "• ")
-	text = strings.ReplaceAll(text, "</li>", "\n")
-	
-	// Handle emphasis
-	text = strings.ReplaceAll(text, "<strong>", "**")
-	text = strings.ReplaceAll(text, "</strong>", "**")
-	text = strings.ReplaceAll(text, "<em>", "*")
-	text = strings.ReplaceAll(text, "</em>", "*")
-	
-	// Remove remaining HTML tags with a simple regex approach
-	// Remove style attributes
-	text = removeStyleAttributes(text)
-	
-	// Remove remaining tags
-	text = removeHTMLTags(text)
-	
-	// Aggressive whitespace cleanup
-	lines := strings.Split(text, "\n")
-	var cleanLines []string
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" {
-			cleanLines = append(cleanLines, line)
-		}
-	}
-	text = strings.Join(cleanLines, "\n")
-	
-	// Remove excessive newlines
-	for strings.Contains(text, "\n\n\n") {
-		text = strings.ReplaceAll(text, "\n\n\n", "\n\n")
-	}
-	
-	text = strings.TrimSpace(text)
-	
-	// Truncate to reasonable length
-	if len(text) > 800 {
-		text = text[:800] + "..."
-	}
-	
-	// Convert newlines to HTML breaks for display
-	text = strings.ReplaceAll(text, "\n", "<br>")
-	
-	return template.HTML(text)
-}
-
-func removeStyleAttributes(text string) string {
-	// Remove style attributes
-	for {
-		start := strings.Index(text, " style=\"")
-		if start == -1 {
-			break
-		}
-		end := strings.Index(text[start+8:], "\"")
-		if end == -1 {
-			break
-		}
-		text = text[:start] + text[start+8+end+1:]
-	}
-	return text
-}
-
-func removeHTMLTags(text string) string {
-	// Simple HTML tag removal
-	result := ""
-	inTag := false
-	
-	for _, char := range text {
-		if char == '<' {
-			inTag = true
-		} else if char == '>' {
-			inTag = false
-		} else if !inTag {
-			result += string(char)
-		}
-	}
-	
-	return result
-}
-
-func buildEnhancedQuery(query string, from, size int) map[string]interface{} {
-	// Detect if it's a phrase search (quoted)
-	isPhrase := strings.HasPrefix(query, "\"") && strings.HasSuffix(query, "\"")
-	if isPhrase {
-		query = strings.Trim(query, "\"")
-		return buildPhraseQuery(query, from, size)
-	}
-
-	// Use function scoring with recency boost
-
-	return map[string]interface{}{
-		"query": map[string]interface{}{
-			"function_score": map[string]interface{}{
-				"query": map[string]interface{}{
-					"bool": map[string]interface{}{
-						"should": []map[string]interface{}{
-							// Exact match (highest boost)
-							{
-								"multi_match": map[string]interface{}{
-									"query":  query,
-									"fields": []string{"title^5", "body^2"},
-									"type":   "phrase",
-									"boost":  10,
-								},
-							},
-							// Fuzzy match for typos
-							{
-								"multi_match": map[string]interface{}{
-									"query":     query,
-									"fields":    []string{"title^3", "body^1"},
-									"fuzziness": "AUTO",
-									"boost":     5,
-								},
-							},
-							// Prefix match for partial typing
-							{
-								"multi_match": map[string]interface{}{
-									"query":  query,
-									"fields": []string{"title^2", "body^1"},
-									"type":   "phrase_prefix",
-									"boost":  3,
-								},
-							},
-						},
-						"minimum_should_match": 1,
-					},
-				},
-				"boost_mode": "multiply",
-				"functions": []map[string]interface{}{
-					{
-						"gauss": map[string]interface{}{
-							"updated_at": map[string]interface{}{
-								"scale": "30d",
-								"decay": 0.5,
-							},
-						},
-						"weight": 1.2,
-					},
-				},
-			},
-		},
-		"from": from,
-		"size": size,
-		"sort": []map[string]interface{}{
-			{"_score": map[string]string{"order": "desc"}},
-		},
-		"highlight": map[string]interface{}{
-			"fields": map[string]interface{}{
-				"title": map[string]interface{}{"fragment_size": 150},
-				"body":  map[string]interface{}{"fragment_size": 300},
-			},
-			"pre_tags":  []string{"<mark>"},
-			"post_tags": []string{"</mark>"},
-		},
-	}
-}
-
-func buildPhraseQuery(query string, from, size int) map[string]interface{} {
-	return map[string]interface{}{
-		"query": map[string]interface{}{
-			"multi_match": map[string]interface{}{
-				"query":  query,
-				"fields": []string{"title^3", "body^1"},
-				"type":   "phrase",
-			},
-		},
-		"from": from,
-		"size": size,
-		"sort": []map[string]interface{}{
-			{"_score": map[string]string{"order": "desc"}},
-			{"updated_at": map[string]string{"order": "desc"}},
-		},
-		"highlight": map[string]interface{}{
-			"fields": map[string]interface{}{
-				"title": map[string]interface{}{},
-				"body":  map[string]interface{}{},
-			},
-			"pre_tags":  []string{"<mark>"},
-			"post_tags": []string{"</mark>"},
-		},
+// snippet shows the start of an article's plain-text body, which the crawler renders from the
+// HTML with a real parser, escaped and with line breaks kept
+func snippet(text string) template.HTML {
+	if runes := []rune(text); len(runes) > 800 {
+		text = string(runes[:800]) + "..."
 	}
+	return template.HTML(strings.ReplaceAll(template.HTMLEscapeString(text), "\n", "<br>"))
 }
 
 func getCachedResult(key string) (SearchResult, bool) {
@@ -1086,6 +1000,7 @@ func getAutocompleteSuggestions(query string) []string {
 						},
 					},
 				},
+				"must_not": search.ExcludeDeletedArticles(),
 			},
 		},
 		"size": 5,